@@ -0,0 +1,270 @@
+package httpclient
+
+import (
+	"math"
+	"time"
+)
+
+// Backoff is the interface to be implemented by the custom backoff strategies
+// Next returns the wait before the retry, with retry being 1 for the first retry of a request
+type Backoff interface {
+	Next(retry int) time.Duration
+}
+
+// BackoffStrategy is the name of the backoff strategy used by the backoff policy
+type BackoffStrategy string
+
+// supported backoff strategies, in the order of their precedence when the strategy is not set
+const (
+	BackoffStrategyConstant           BackoffStrategy = "constant"
+	BackoffStrategyExponential        BackoffStrategy = "exponential"
+	BackoffStrategyFullJitter         BackoffStrategy = "fulljitter"
+	BackoffStrategyEqualJitter        BackoffStrategy = "equaljitter"
+	BackoffStrategyDecorrelatedJitter BackoffStrategy = "decorrelatedjitter"
+	BackoffStrategyFibonacci          BackoffStrategy = "fibonacci"
+	BackoffStrategyLinear             BackoffStrategy = "linear"
+	BackoffStrategyCustom             BackoffStrategy = "custom"
+)
+
+var backoffStrategies = []BackoffStrategy{
+	BackoffStrategyConstant,
+	BackoffStrategyExponential,
+	BackoffStrategyFullJitter,
+	BackoffStrategyEqualJitter,
+	BackoffStrategyDecorrelatedJitter,
+	BackoffStrategyFibonacci,
+	BackoffStrategyLinear,
+	BackoffStrategyCustom,
+}
+
+const defaultExponentFactor = 2.0
+
+// JitterBackoff is used to create a new full, equal or decorrelated jitter backoff
+type JitterBackoff struct {
+	initialTimeout time.Duration
+	maxTimeout     time.Duration
+}
+
+// NewJitterBackoff is used to create a new jitter backoff
+func NewJitterBackoff(configMap map[string]interface{}) *JitterBackoff {
+	jitterBackoff := &JitterBackoff{}
+	initialTimeout, err := getConfigOptionInt(configMap, "initialtimeoutinmillis")
+	if err == nil {
+		jitterBackoff.initialTimeout = time.Duration(initialTimeout) * time.Millisecond
+	}
+	maxTimeout, err := getConfigOptionInt(configMap, "maxtimeoutinmillis")
+	if err == nil {
+		jitterBackoff.maxTimeout = time.Duration(maxTimeout) * time.Millisecond
+	}
+	return jitterBackoff
+}
+
+// SetInitialTimeout is used to set the base wait time, doubled for every retry
+func (jb *JitterBackoff) SetInitialTimeout(initialTimeout time.Duration) *JitterBackoff {
+	jb.initialTimeout = initialTimeout
+	return jb
+}
+
+// SetMaxTimeout is used to set the maximum wait time
+// if not done, then the wait time is not capped
+func (jb *JitterBackoff) SetMaxTimeout(maxTimeout time.Duration) *JitterBackoff {
+	jb.maxTimeout = maxTimeout
+	return jb
+}
+
+// FibonacciBackoff is used to create a new fibonacci backoff
+type FibonacciBackoff struct {
+	interval              time.Duration
+	maxTimeout            time.Duration
+	maximumJitterInterval time.Duration
+}
+
+// NewFibonacciBackoff is used to create a new fibonacci backoff
+func NewFibonacciBackoff(configMap map[string]interface{}) *FibonacciBackoff {
+	fibonacciBackoff := &FibonacciBackoff{}
+	interval, err := getConfigOptionInt(configMap, "intervalinmillis")
+	if err == nil {
+		fibonacciBackoff.interval = time.Duration(interval) * time.Millisecond
+	}
+	maxTimeout, err := getConfigOptionInt(configMap, "maxtimeoutinmillis")
+	if err == nil {
+		fibonacciBackoff.maxTimeout = time.Duration(maxTimeout) * time.Millisecond
+	}
+	maximumJitterInterval, err := getConfigOptionInt(configMap, "maxjitterintervalinmillis")
+	if err == nil {
+		fibonacciBackoff.maximumJitterInterval = time.Duration(maximumJitterInterval) * time.Millisecond
+	}
+	return fibonacciBackoff
+}
+
+// SetInterval is used to set the interval multiplied by the fibonacci number of the retry
+func (fb *FibonacciBackoff) SetInterval(interval time.Duration) *FibonacciBackoff {
+	fb.interval = interval
+	return fb
+}
+
+// SetMaxTimeout is used to set the maximum wait time
+// if not done, then the wait time is not capped
+func (fb *FibonacciBackoff) SetMaxTimeout(maxTimeout time.Duration) *FibonacciBackoff {
+	fb.maxTimeout = maxTimeout
+	return fb
+}
+
+// SetMaximumJitterInterval is used to set the jitter interval
+func (fb *FibonacciBackoff) SetMaximumJitterInterval(maximumJitterInterval time.Duration) *FibonacciBackoff {
+	fb.maximumJitterInterval = maximumJitterInterval
+	return fb
+}
+
+// LinearBackoff is used to create a new linear backoff
+type LinearBackoff struct {
+	initialTimeout        time.Duration
+	increment             time.Duration
+	maxTimeout            time.Duration
+	maximumJitterInterval time.Duration
+}
+
+// NewLinearBackoff is used to create a new linear backoff
+func NewLinearBackoff(configMap map[string]interface{}) *LinearBackoff {
+	linearBackoff := &LinearBackoff{}
+	initialTimeout, err := getConfigOptionInt(configMap, "initialtimeoutinmillis")
+	if err == nil {
+		linearBackoff.initialTimeout = time.Duration(initialTimeout) * time.Millisecond
+	}
+	increment, err := getConfigOptionInt(configMap, "incrementinmillis")
+	if err == nil {
+		linearBackoff.increment = time.Duration(increment) * time.Millisecond
+	}
+	maxTimeout, err := getConfigOptionInt(configMap, "maxtimeoutinmillis")
+	if err == nil {
+		linearBackoff.maxTimeout = time.Duration(maxTimeout) * time.Millisecond
+	}
+	maximumJitterInterval, err := getConfigOptionInt(configMap, "maxjitterintervalinmillis")
+	if err == nil {
+		linearBackoff.maximumJitterInterval = time.Duration(maximumJitterInterval) * time.Millisecond
+	}
+	return linearBackoff
+}
+
+// SetInitialTimeout is used to set the wait time before the first retry
+func (lb *LinearBackoff) SetInitialTimeout(initialTimeout time.Duration) *LinearBackoff {
+	lb.initialTimeout = initialTimeout
+	return lb
+}
+
+// SetIncrement is used to set the wait time added for every subsequent retry
+func (lb *LinearBackoff) SetIncrement(increment time.Duration) *LinearBackoff {
+	lb.increment = increment
+	return lb
+}
+
+// SetMaxTimeout is used to set the maximum wait time
+// if not done, then the wait time is not capped
+func (lb *LinearBackoff) SetMaxTimeout(maxTimeout time.Duration) *LinearBackoff {
+	lb.maxTimeout = maxTimeout
+	return lb
+}
+
+// SetMaximumJitterInterval is used to set the jitter interval
+func (lb *LinearBackoff) SetMaximumJitterInterval(maximumJitterInterval time.Duration) *LinearBackoff {
+	lb.maximumJitterInterval = maximumJitterInterval
+	return lb
+}
+
+// backoffFunc adapts a function to the Backoff interface
+type backoffFunc func(retry int) time.Duration
+
+// Next returns the wait before the retry
+func (f backoffFunc) Next(retry int) time.Duration {
+	return f(retry)
+}
+
+// noBackoff retries without waiting
+var noBackoff = backoffFunc(func(int) time.Duration { return 0 })
+
+func (cb *ConstantBackoff) next(retry int) time.Duration {
+	return cb.interval + randomDuration(cb.maximumJitterInterval)
+}
+
+func (eb *ExponentialBackoff) next(retry int) time.Duration {
+	exponentFactor := eb.exponentFactor
+	if exponentFactor == 0 {
+		exponentFactor = defaultExponentFactor
+	}
+	return capDuration(float64(eb.initialTimeout)*math.Pow(exponentFactor, float64(retry-1)), eb.maxTimeout) +
+		randomDuration(eb.maximumJitterInterval)
+}
+
+// This waits a random time between zero and the exponentially growing wait.
+func (jb *JitterBackoff) nextFullJitter(retry int) time.Duration {
+	return randomDuration(jb.exponential(retry))
+}
+
+// This waits half of the exponentially growing wait and a random time up to the other half.
+func (jb *JitterBackoff) nextEqualJitter(retry int) time.Duration {
+	wait := jb.exponential(retry)
+	return wait/2 + randomDuration(wait-wait/2)
+}
+
+// This waits a random time between the initial timeout and thrice the previous wait. As the interface does not
+// keep the previous wait for the request, the waits leading to the retry are drawn again, which gives the
+// same distribution without any state shared by the requests.
+func (jb *JitterBackoff) nextDecorrelatedJitter(retry int) time.Duration {
+	wait := jb.initialTimeout
+	for i := 1; i <= retry; i++ {
+		upper := capDuration(float64(wait)*3, jb.maxTimeout)
+		if upper < jb.initialTimeout {
+			upper = jb.initialTimeout
+		}
+		wait = jb.initialTimeout + randomDuration(upper-jb.initialTimeout)
+		if jb.maxTimeout > 0 && wait >= jb.maxTimeout {
+			return jb.maxTimeout
+		}
+	}
+	return wait
+}
+
+func (jb *JitterBackoff) exponential(retry int) time.Duration {
+	return capDuration(float64(jb.initialTimeout)*math.Pow(defaultExponentFactor, float64(retry-1)), jb.maxTimeout)
+}
+
+func (fb *FibonacciBackoff) next(retry int) time.Duration {
+	previous, current := 0.0, 1.0
+	for i := 1; i < retry; i++ {
+		previous, current = current, previous+current
+		if fb.maxTimeout > 0 && float64(fb.interval)*current >= float64(fb.maxTimeout) {
+			break
+		}
+	}
+	return capDuration(float64(fb.interval)*current, fb.maxTimeout) + randomDuration(fb.maximumJitterInterval)
+}
+
+func (lb *LinearBackoff) next(retry int) time.Duration {
+	return capDuration(float64(lb.initialTimeout)+float64(lb.increment)*float64(retry-1), lb.maxTimeout) +
+		randomDuration(lb.maximumJitterInterval)
+}
+
+// This converts the wait to a duration capped by the max timeout, if set, and by the largest duration.
+func capDuration(wait float64, maxTimeout time.Duration) time.Duration {
+	if maxTimeout > 0 && wait > float64(maxTimeout) {
+		return maxTimeout
+	}
+	if wait >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	if wait < 0 {
+		return 0
+	}
+	return time.Duration(wait)
+}
+
+// This returns a random duration between zero and max, both included.
+func randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	if max == math.MaxInt64 {
+		return time.Duration(random.Int63())
+	}
+	return time.Duration(random.Int63n(int64(max) + 1))
+}
@@ -0,0 +1,99 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingBackoff struct {
+	retries []int
+}
+
+func (b *recordingBackoff) Next(retry int) time.Duration {
+	b.retries = append(b.retries, retry)
+	return time.Millisecond
+}
+
+func TestBackoffStrategies(t *testing.T) {
+	// the waits start from the initial timeout for the first retry and do not panic without jitter
+	exponential := NewBackoffPolicy(nil).SetExponentialBackoff(NewExponentialBackoff(nil).
+		SetInitialTimeout(10 * time.Millisecond).SetMaxTimeout(50 * time.Millisecond)).getBackoff()
+	assert.Equal(t, exponential.Next(1), 10*time.Millisecond)
+	assert.Equal(t, exponential.Next(2), 20*time.Millisecond)
+	assert.Equal(t, exponential.Next(4), 50*time.Millisecond)
+
+	constant := NewBackoffPolicy(nil).SetConstantBackoff(NewConstantBackoff(nil).
+		SetInterval(10 * time.Millisecond)).getBackoff()
+	assert.Equal(t, constant.Next(1), 10*time.Millisecond)
+
+	fibonacci := NewBackoffPolicy(map[string]interface{}{
+		"fibonacciBackoff": map[string]interface{}{"intervalinmillis": 10, "maxtimeoutinmillis": 70},
+	}).getBackoff()
+	var waits []time.Duration
+	for retry := 1; retry <= 6; retry++ {
+		waits = append(waits, fibonacci.Next(retry)/time.Millisecond)
+	}
+	assert.Equal(t, waits, []time.Duration{10, 10, 20, 30, 50, 70})
+
+	linear := NewBackoffPolicy(nil).SetLinearBackoff(NewLinearBackoff(nil).SetInitialTimeout(10 * time.Millisecond).
+		SetIncrement(5 * time.Millisecond).SetMaxTimeout(22 * time.Millisecond)).getBackoff()
+	assert.Equal(t, linear.Next(1), 10*time.Millisecond)
+	assert.Equal(t, linear.Next(3), 20*time.Millisecond)
+	assert.Equal(t, linear.Next(4), 22*time.Millisecond)
+
+	jitterBackoff := NewJitterBackoff(nil).SetInitialTimeout(10 * time.Millisecond).SetMaxTimeout(100 * time.Millisecond)
+	fullJitter := NewBackoffPolicy(nil).SetFullJitterBackoff(jitterBackoff).getBackoff()
+	equalJitter := NewBackoffPolicy(nil).SetEqualJitterBackoff(jitterBackoff).getBackoff()
+	decorrelatedJitter := NewBackoffPolicy(nil).SetDecorrelatedJitterBackoff(jitterBackoff).getBackoff()
+	for i := 0; i < 100; i++ {
+		require.True(t, fullJitter.Next(3) <= 40*time.Millisecond)
+		wait := equalJitter.Next(3)
+		require.True(t, wait >= 20*time.Millisecond && wait <= 40*time.Millisecond)
+		wait = decorrelatedJitter.Next(5)
+		require.True(t, wait >= 10*time.Millisecond && wait <= 100*time.Millisecond)
+	}
+
+	// the strategy picks one of the configured backoffs, which otherwise conflict
+	policy, err := NewRequestConfigStrict("test", map[string]interface{}{
+		"method": http.MethodGet,
+		"url":    "http://localhost",
+		"backoffpolicy": map[string]interface{}{
+			"strategy":           "linear",
+			"constantbackoff":    map[string]interface{}{"intervalinmillis": 1},
+			"linearbackoff":      map[string]interface{}{"initialtimeoutinmillis": 3},
+			"fulljitterbackoff":  map[string]interface{}{"initialtimeoutinmillis": 5},
+			"exponentialbackoff": map[string]interface{}{"initialtimeoutinmillis": 2, "maxtimeoutinmillis": 4},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, policy.backoffPolicy.getBackoff().Next(1), 3*time.Millisecond)
+
+	_, err = NewRequestConfigStrict("test", map[string]interface{}{
+		"method":        http.MethodGet,
+		"url":           "http://localhost",
+		"backoffpolicy": map[string]interface{}{"strategy": "fibonacci"},
+	})
+	require.Error(t, err)
+
+	// the custom backoff is asked for the wait before every retry
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	custom := &recordingBackoff{}
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetRetryCount(3).SetBackoffPolicy(NewBackoffPolicy(nil).SetBackoff(custom)))
+	_, err = client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(4))
+	assert.Equal(t, custom.retries, []int{1, 2, 3})
+}
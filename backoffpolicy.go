@@ -1,11 +1,24 @@
 package httpclient
 
-import "time"
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // BackoffPolicy is the type for backoff policy
 type BackoffPolicy struct {
-	constantBackoff    *ConstantBackoff
-	exponentialBackoff *ExponentialBackoff
+	strategy                  BackoffStrategy
+	constantBackoff           *ConstantBackoff
+	exponentialBackoff        *ExponentialBackoff
+	fullJitterBackoff         *JitterBackoff
+	equalJitterBackoff        *JitterBackoff
+	decorrelatedJitterBackoff *JitterBackoff
+	fibonacciBackoff          *FibonacciBackoff
+	linearBackoff             *LinearBackoff
+	backoff                   Backoff
+	maxRetryAfter             time.Duration
 }
 
 // NewBackoffPolicy is used to create a new backoff policy
@@ -22,21 +35,217 @@ func NewBackoffPolicy(configMap map[string]interface{}) *BackoffPolicy {
 		backoffPolicy.exponentialBackoff = NewExponentialBackoff(exponentialBackoffMap)
 	}
 
+	fullJitterBackoffMap, err := getConfigOptionMap(configMap, "fulljitterbackoff")
+	if err == nil {
+		backoffPolicy.fullJitterBackoff = NewJitterBackoff(fullJitterBackoffMap)
+	}
+
+	equalJitterBackoffMap, err := getConfigOptionMap(configMap, "equaljitterbackoff")
+	if err == nil {
+		backoffPolicy.equalJitterBackoff = NewJitterBackoff(equalJitterBackoffMap)
+	}
+
+	decorrelatedJitterBackoffMap, err := getConfigOptionMap(configMap, "decorrelatedjitterbackoff")
+	if err == nil {
+		backoffPolicy.decorrelatedJitterBackoff = NewJitterBackoff(decorrelatedJitterBackoffMap)
+	}
+
+	fibonacciBackoffMap, err := getConfigOptionMap(configMap, "fibonaccibackoff")
+	if err == nil {
+		backoffPolicy.fibonacciBackoff = NewFibonacciBackoff(fibonacciBackoffMap)
+	}
+
+	linearBackoffMap, err := getConfigOptionMap(configMap, "linearbackoff")
+	if err == nil {
+		backoffPolicy.linearBackoff = NewLinearBackoff(linearBackoffMap)
+	}
+
+	strategy, err := getConfigOptionString(configMap, "strategy")
+	if err == nil {
+		backoffPolicy.strategy = BackoffStrategy(strings.ToLower(strategy))
+	}
+
+	maxRetryAfter, err := getConfigOptionInt(configMap, "maxretryafterinmillis")
+	if err == nil {
+		backoffPolicy.maxRetryAfter = time.Duration(maxRetryAfter) * time.Millisecond
+	}
+
 	return backoffPolicy
 }
 
+// SetStrategy is used to set the strategy used among the ones configured
+// if not done, then the one set last is used, or the first configured one in the order of the BackoffStrategy constants
+func (bop *BackoffPolicy) SetStrategy(strategy BackoffStrategy) *BackoffPolicy {
+	bop.strategy = strategy
+	return bop
+}
+
 // SetConstantBackoff is used to use the constant backoff policy
 func (bop *BackoffPolicy) SetConstantBackoff(constantBackoff *ConstantBackoff) *BackoffPolicy {
 	bop.constantBackoff = constantBackoff
+	bop.strategy = BackoffStrategyConstant
 	return bop
 }
 
 // SetExponentialBackoff is used to set the exponential backoff policy
 func (bop *BackoffPolicy) SetExponentialBackoff(exponentialBackoff *ExponentialBackoff) *BackoffPolicy {
 	bop.exponentialBackoff = exponentialBackoff
+	bop.strategy = BackoffStrategyExponential
+	return bop
+}
+
+// SetFullJitterBackoff is used to set the backoff waiting a random time up to the exponentially growing wait
+func (bop *BackoffPolicy) SetFullJitterBackoff(jitterBackoff *JitterBackoff) *BackoffPolicy {
+	bop.fullJitterBackoff = jitterBackoff
+	bop.strategy = BackoffStrategyFullJitter
+	return bop
+}
+
+// SetEqualJitterBackoff is used to set the backoff waiting half of the exponentially growing wait
+// and a random time up to the other half
+func (bop *BackoffPolicy) SetEqualJitterBackoff(jitterBackoff *JitterBackoff) *BackoffPolicy {
+	bop.equalJitterBackoff = jitterBackoff
+	bop.strategy = BackoffStrategyEqualJitter
+	return bop
+}
+
+// SetDecorrelatedJitterBackoff is used to set the backoff waiting a random time between the initial timeout
+// and thrice the previous wait
+func (bop *BackoffPolicy) SetDecorrelatedJitterBackoff(jitterBackoff *JitterBackoff) *BackoffPolicy {
+	bop.decorrelatedJitterBackoff = jitterBackoff
+	bop.strategy = BackoffStrategyDecorrelatedJitter
 	return bop
 }
 
+// SetFibonacciBackoff is used to set the fibonacci backoff policy
+func (bop *BackoffPolicy) SetFibonacciBackoff(fibonacciBackoff *FibonacciBackoff) *BackoffPolicy {
+	bop.fibonacciBackoff = fibonacciBackoff
+	bop.strategy = BackoffStrategyFibonacci
+	return bop
+}
+
+// SetLinearBackoff is used to set the linear backoff policy
+func (bop *BackoffPolicy) SetLinearBackoff(linearBackoff *LinearBackoff) *BackoffPolicy {
+	bop.linearBackoff = linearBackoff
+	bop.strategy = BackoffStrategyLinear
+	return bop
+}
+
+// SetBackoff is used to set a custom backoff strategy
+func (bop *BackoffPolicy) SetBackoff(backoff Backoff) *BackoffPolicy {
+	bop.backoff = backoff
+	bop.strategy = BackoffStrategyCustom
+	return bop
+}
+
+// This gets the backoff for the strategy, which is nil if it is not configured.
+func (bop *BackoffPolicy) getStrategyBackoff(strategy BackoffStrategy) Backoff {
+	switch strategy {
+	case BackoffStrategyConstant:
+		if bop.constantBackoff != nil {
+			return backoffFunc(bop.constantBackoff.next)
+		}
+	case BackoffStrategyExponential:
+		if bop.exponentialBackoff != nil {
+			return backoffFunc(bop.exponentialBackoff.next)
+		}
+	case BackoffStrategyFullJitter:
+		if bop.fullJitterBackoff != nil {
+			return backoffFunc(bop.fullJitterBackoff.nextFullJitter)
+		}
+	case BackoffStrategyEqualJitter:
+		if bop.equalJitterBackoff != nil {
+			return backoffFunc(bop.equalJitterBackoff.nextEqualJitter)
+		}
+	case BackoffStrategyDecorrelatedJitter:
+		if bop.decorrelatedJitterBackoff != nil {
+			return backoffFunc(bop.decorrelatedJitterBackoff.nextDecorrelatedJitter)
+		}
+	case BackoffStrategyFibonacci:
+		if bop.fibonacciBackoff != nil {
+			return backoffFunc(bop.fibonacciBackoff.next)
+		}
+	case BackoffStrategyLinear:
+		if bop.linearBackoff != nil {
+			return backoffFunc(bop.linearBackoff.next)
+		}
+	case BackoffStrategyCustom:
+		return bop.backoff
+	}
+	return nil
+}
+
+// This gets the backoff of the strategy set, or else of the first configured strategy.
+// The retries are made without waiting if there is none.
+func (bop *BackoffPolicy) getBackoff() Backoff {
+	if bop == nil {
+		return noBackoff
+	}
+	if bop.strategy != "" {
+		if backoff := bop.getStrategyBackoff(bop.strategy); backoff != nil {
+			return backoff
+		}
+		return noBackoff
+	}
+	for _, strategy := range backoffStrategies {
+		if backoff := bop.getStrategyBackoff(strategy); backoff != nil {
+			return backoff
+		}
+	}
+	return noBackoff
+}
+
+// SetMaxRetryAfter is used to set the most the Retry-After header of the responses is honoured for, waiting at most
+// maxRetryAfter. The configured backoff is used when the header is absent. If not done, then 30 seconds is used
+func (bop *BackoffPolicy) SetMaxRetryAfter(maxRetryAfter time.Duration) *BackoffPolicy {
+	bop.maxRetryAfter = maxRetryAfter
+	return bop
+}
+
+// This gets the wait before the next attempt as per the Retry-After header of the response, capped by maxRetryAfter,
+// or the default cap when there is no backoff policy or it is not set. It returns false if the header is absent or
+// invalid.
+func (bop *BackoffPolicy) retryAfter(response *http.Response, now time.Time) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	wait, ok := parseRetryAfter(response.Header.Get(retryAfterHeader), now)
+	if !ok {
+		return 0, false
+	}
+	maxRetryAfter := defaultMaxRetryAfter
+	if bop != nil && bop.maxRetryAfter > 0 {
+		maxRetryAfter = bop.maxRetryAfter
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
+}
+
+// This parses the Retry-After header value, which is either the delay in seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	wait := date.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
 // ConstantBackoff is used to create a new constant backoff
 type ConstantBackoff struct {
 	interval              time.Duration
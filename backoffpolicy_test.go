@@ -0,0 +1,64 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	wait, ok := parseRetryAfter("120", now)
+	require.True(t, ok)
+	assert.Equal(t, wait, 2*time.Minute)
+
+	wait, ok = parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now)
+	require.True(t, ok)
+	assert.Equal(t, wait, 30*time.Second)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.Equal(t, ok, false)
+
+	// the header is honoured without a backoff policy as well, up to the default cap
+	response := &http.Response{Header: http.Header{"Retry-After": []string{"10"}}}
+	var policy *BackoffPolicy
+	wait, ok = policy.retryAfter(response, now)
+	require.True(t, ok)
+	assert.Equal(t, wait, 10*time.Second)
+	response.Header.Set("Retry-After", "120")
+	wait, ok = policy.retryAfter(response, now)
+	require.True(t, ok)
+	assert.Equal(t, wait, defaultMaxRetryAfter)
+	wait, ok = NewBackoffPolicy(nil).retryAfter(response, now)
+	require.True(t, ok)
+	assert.Equal(t, wait, defaultMaxRetryAfter)
+	_, ok = policy.retryAfter(&http.Response{Header: http.Header{}}, now)
+	assert.Equal(t, ok, false)
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetRetryCount(1).SetBackoffPolicy(NewBackoffPolicy(map[string]interface{}{"maxRetryAfterInMillis": 50})))
+
+	start := time.Now()
+	res, err := client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	require.True(t, time.Since(start) >= 50*time.Millisecond)
+	require.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,169 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// ErrBodyNotReplayable is returned when an attempt of the request is to be retried, but its body
+// cannot be sent again as it is larger than the max body buffer size and cannot be created again
+var ErrBodyNotReplayable = errors.New("request body cannot be replayed for retry")
+
+// requestBody provides the body for every attempt of a request
+type requestBody struct {
+	get        func() (io.ReadCloser, error)
+	replayable bool
+	close      func() error
+}
+
+// This creates the request body to be sent in every attempt. The body is created again using the body func of
+// the Request, the GetBody of the http request or by seeking back the reader when possible. Otherwise it is
+// buffered in memory up to the max buffer size, with zero meaning the default of 4 MiB and a negative size meaning
+// no limit, and a larger body is sent only once.
+func newRequestBody(request *Request, req *http.Request, retryCount int, maxBufferSize int64) (*requestBody, error) {
+	if request.bodyFunc != nil {
+		return &requestBody{get: func() (io.ReadCloser, error) {
+			r, err := request.bodyFunc()
+			if err != nil {
+				return nil, err
+			}
+			if rc, ok := r.(io.ReadCloser); ok {
+				return rc, nil
+			}
+			return ioutil.NopCloser(r), nil
+		}, replayable: true}, nil
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return &requestBody{replayable: true}, nil
+	}
+	if req.GetBody != nil {
+		return &requestBody{get: req.GetBody, replayable: true}, nil
+	}
+	if seeker, ok := request.body.(io.ReadSeeker); ok {
+		return newSeekerBody(seeker)
+	}
+	if retryCount <= 0 {
+		return newSingleUseBody(req.Body), nil
+	}
+
+	if maxBufferSize == 0 {
+		maxBufferSize = defaultMaxBodyBufferSize
+	}
+	var data []byte
+	var err error
+	if maxBufferSize > 0 {
+		data, err = ioutil.ReadAll(io.LimitReader(req.Body, maxBufferSize+1))
+	} else {
+		data, err = ioutil.ReadAll(req.Body)
+	}
+	if err != nil {
+		_ = req.Body.Close()
+		return nil, err
+	}
+	if maxBufferSize > 0 && int64(len(data)) > maxBufferSize {
+		// too large to be kept in memory, so the rest of it is streamed after what is already read
+		return newSingleUseBody(struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}), nil
+	}
+	_ = req.Body.Close()
+	return &requestBody{get: func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}, replayable: true}, nil
+}
+
+// This creates the body which is sent again from the position where the reader is. As the transport may still be
+// reading the body of an attempt after it is done, every attempt reads its own section of a reader which supports
+// ReadAt, and otherwise the reader is seeked back only once the body of the previous attempt is closed.
+// The reader is closed once the request is done, if it is a closer.
+func newSeekerBody(seeker io.ReadSeeker) (*requestBody, error) {
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	body := &requestBody{replayable: true}
+	if readerAt, ok := seeker.(io.ReaderAt); ok {
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		body.get = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(io.NewSectionReader(readerAt, offset, end-offset)), nil
+		}
+	} else {
+		var previous *closeNotifyBody
+		body.get = func() (io.ReadCloser, error) {
+			if previous != nil {
+				<-previous.closed
+			}
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			previous = &closeNotifyBody{Reader: seeker, closed: make(chan struct{})}
+			return previous, nil
+		}
+	}
+	if closer, ok := seeker.(io.Closer); ok {
+		body.close = closer.Close
+	}
+	return body, nil
+}
+
+// closeNotifyBody is the body of an attempt which tells when it is closed
+type closeNotifyBody struct {
+	io.Reader
+	once   sync.Once
+	closed chan struct{}
+}
+
+// Close is used to tell that the body is closed, without closing the reader shared by the attempts
+func (b *closeNotifyBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+// This closes the body of a request which is not made, as the transport would have, so that the body of the next
+// attempt can be created.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+func newSingleUseBody(rc io.ReadCloser) *requestBody {
+	sent := false
+	return &requestBody{get: func() (io.ReadCloser, error) {
+		if sent {
+			return nil, ErrBodyNotReplayable
+		}
+		sent = true
+		return rc, nil
+	}}
+}
+
+// This releases the body once all the attempts are done.
+func (b *requestBody) release() {
+	if b.close != nil {
+		_ = b.close()
+	}
+}
+
+// This creates the error returned when the attempt which failed cannot be retried as the body cannot be replayed.
+func getBodyNotReplayableError(response *http.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("%w, the attempt failed with %v", ErrBodyNotReplayable, err)
+	}
+	if response == nil {
+		return ErrBodyNotReplayable
+	}
+	return fmt.Errorf("%w, the attempt failed with status %d", ErrBodyNotReplayable, response.StatusCode)
+}
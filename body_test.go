@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyReplay(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(data))
+		attempt := len(bodies)
+		mu.Unlock()
+		if attempt%2 == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodPut).SetURL(server.URL).
+		SetRetryCount(1).SetMaxBodyBufferSize(8))
+	reset := func() {
+		mu.Lock()
+		bodies = nil
+		mu.Unlock()
+	}
+
+	// a reader which is neither seekable nor known to net/http is buffered if it fits
+	res, err := client.Request(NewRequest("test").SetBody(io.MultiReader(strings.NewReader("small"))))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, bodies, []string{"small", "small"})
+
+	reset()
+	_, err = client.Request(NewRequest("test").SetBody(io.MultiReader(strings.NewReader("larger than the buffer"))))
+	require.True(t, errors.Is(err, ErrBodyNotReplayable))
+	assert.Equal(t, bodies, []string{"larger than the buffer"})
+
+	reset()
+	_, err = client.Request(NewRequest("test").SetBody(struct{ io.ReadSeeker }{strings.NewReader("seekable body")}))
+	require.NoError(t, err)
+	assert.Equal(t, bodies, []string{"seekable body", "seekable body"})
+
+	reset()
+	_, err = client.Request(NewRequest("test").SetBodyFunc(func() (io.Reader, error) {
+		return io.MultiReader(strings.NewReader("created body")), nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, bodies, []string{"created body", "created body"})
+}
+
+func TestSeekerBodyAttempts(t *testing.T) {
+	// every attempt reads its own section of a reader supporting ReadAt, unaffected by the other attempts
+	body, err := newSeekerBody(strings.NewReader("seekable body"))
+	require.NoError(t, err)
+	first, err := body.get()
+	require.NoError(t, err)
+	data := make([]byte, 4)
+	_, err = io.ReadFull(first, data)
+	require.NoError(t, err)
+	second, err := body.get()
+	require.NoError(t, err)
+	secondData, err := ioutil.ReadAll(second)
+	require.NoError(t, err)
+	assert.Equal(t, string(secondData), "seekable body")
+	rest, err := ioutil.ReadAll(first)
+	require.NoError(t, err)
+	assert.Equal(t, string(data)+string(rest), "seekable body")
+
+	// the other readers are seeked back only once the body of the previous attempt is closed
+	body, err = newSeekerBody(struct{ io.ReadSeeker }{strings.NewReader("seekable body")})
+	require.NoError(t, err)
+	first, err = body.get()
+	require.NoError(t, err)
+	next := make(chan io.ReadCloser)
+	go func() {
+		rc, _ := body.get()
+		next <- rc
+	}()
+	select {
+	case <-next:
+		t.Fatal("the body of the next attempt is created before the previous one is closed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	require.NoError(t, first.Close())
+	secondData, err = ioutil.ReadAll(<-next)
+	require.NoError(t, err)
+	assert.Equal(t, string(secondData), "seekable body")
+}
+
+func TestBodyNotReplayableBudget(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodPut).SetURL(server.URL).
+		SetRetryCount(1).SetMaxBodyBufferSize(8).
+		SetRetryBudget(NewRetryBudget(nil).SetMinRetriesPerSecond(1).SetWindow(time.Second)))
+
+	// the retry which is not made as the body cannot be replayed does not use up the budget
+	_, err := client.Request(NewRequest("test").SetBody(io.MultiReader(strings.NewReader("larger than the buffer"))))
+	require.True(t, errors.Is(err, ErrBodyNotReplayable))
+	res, err := client.Request(NewRequest("test").SetBody(io.MultiReader(strings.NewReader("small"))))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(3))
+}
+
+func TestBodyBufferSizeDefault(t *testing.T) {
+	newBody := func(size int, maxBufferSize int64) *requestBody {
+		request := NewRequest("test").SetBody(io.MultiReader(strings.NewReader(strings.Repeat("a", size))))
+		req, err := http.NewRequest(http.MethodPut, "http://localhost", request.body)
+		require.NoError(t, err)
+		body, err := newRequestBody(request, req, 1, maxBufferSize)
+		require.NoError(t, err)
+		return body
+	}
+
+	// zero means the default size, and a negative size means no limit
+	require.True(t, newBody(int(defaultMaxBodyBufferSize), 0).replayable)
+	require.False(t, newBody(int(defaultMaxBodyBufferSize)+1, 0).replayable)
+	require.True(t, newBody(int(defaultMaxBodyBufferSize)+1, -1).replayable)
+}
@@ -0,0 +1,233 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gojek/heimdall"
+)
+
+// Priority decides the order in which the requests waiting in the queue of the bulkhead are made,
+// the requests with a higher priority being made first
+type Priority int
+
+// common priorities, any other value can be used as well
+const (
+	PriorityBackground Priority = -1
+	PriorityNormal     Priority = 0
+	PriorityCritical   Priority = 1
+)
+
+var (
+	// ErrBulkheadFull is matched using errors.Is by the error returned for a request which cannot wait in the queue
+	// of the bulkhead, as it is full of requests with the same or a higher priority
+	ErrBulkheadFull = errors.New("bulkhead is full")
+	// ErrBulkheadTimeout is matched using errors.Is by the error returned for a request which waited in the queue
+	// of the bulkhead for the queue timeout
+	ErrBulkheadTimeout = errors.New("bulkhead queue timeout")
+)
+
+// priorityKey is the context key for the priority of the request
+type priorityKey struct{}
+
+// This gets the priority of the request set in the context.
+func getPriority(ctx context.Context) Priority {
+	priority, _ := ctx.Value(priorityKey{}).(Priority)
+	return priority
+}
+
+// Bulkhead is the type for limiting the requests in flight with the same name, making the requests over the limit
+// wait in a bounded queue, so that a slow endpoint cannot use up all the resources of the application
+type Bulkhead struct {
+	maxConcurrentRequests int
+	maxQueueSize          int
+	queueTimeout          time.Duration
+}
+
+// NewBulkhead is used to create a new bulkhead
+func NewBulkhead(configMap map[string]interface{}) *Bulkhead {
+	bulkhead := &Bulkhead{}
+	bulkhead.maxConcurrentRequests, _ = getConfigOptionInt(configMap, "maxconcurrentrequests")
+	bulkhead.maxQueueSize, _ = getConfigOptionInt(configMap, "maxqueuesize")
+	queueTimeout, err := getConfigOptionInt(configMap, "queuetimeoutinmillis")
+	if err == nil {
+		bulkhead.queueTimeout = time.Duration(queueTimeout) * time.Millisecond
+	}
+	return bulkhead
+}
+
+// SetMaxConcurrentRequests is used to set the requests allowed in flight
+func (b *Bulkhead) SetMaxConcurrentRequests(maxConcurrentRequests int) *Bulkhead {
+	b.maxConcurrentRequests = maxConcurrentRequests
+	return b
+}
+
+// SetMaxQueueSize is used to set the requests allowed to wait when the max concurrent requests are in flight
+// if not done, then the requests over the max concurrent requests fail right away
+func (b *Bulkhead) SetMaxQueueSize(maxQueueSize int) *Bulkhead {
+	b.maxQueueSize = maxQueueSize
+	return b
+}
+
+// SetQueueTimeout is used to set the time a request waits in the queue before failing
+// if not done, then the request waits until its context is done
+func (b *Bulkhead) SetQueueTimeout(queueTimeout time.Duration) *Bulkhead {
+	b.queueTimeout = queueTimeout
+	return b
+}
+
+// bulkheadWaiter is a request waiting in the queue of the bulkhead
+type bulkheadWaiter struct {
+	priority Priority
+	seq      uint64
+	admitted chan error
+}
+
+// bulkheadPool keeps the requests in flight and the queue of the bulkhead, ordered by the priority and then
+// by the time they started waiting
+type bulkheadPool struct {
+	maxConcurrentRequests int
+	maxQueueSize          int
+	queueTimeout          time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	queue    []*bulkheadWaiter
+	seq      uint64
+}
+
+// This creates the pool for the bulkhead, which is nil if there is no bulkhead.
+func newBulkheadPool(bulkhead *Bulkhead) *bulkheadPool {
+	if bulkhead == nil || bulkhead.maxConcurrentRequests <= 0 {
+		return nil
+	}
+	return &bulkheadPool{
+		maxConcurrentRequests: bulkhead.maxConcurrentRequests,
+		maxQueueSize:          bulkhead.maxQueueSize,
+		queueTimeout:          bulkhead.queueTimeout,
+	}
+}
+
+// This admits the request, waiting in the queue if the max concurrent requests are in flight. When the queue is full,
+// the request waiting with the lowest priority, the latest one among them, fails to make room for a request with a
+// higher priority.
+func (p *bulkheadPool) acquire(ctx context.Context, priority Priority) error {
+	p.mu.Lock()
+	if p.inFlight < p.maxConcurrentRequests && len(p.queue) == 0 {
+		p.inFlight++
+		p.mu.Unlock()
+		return nil
+	}
+	if len(p.queue) >= p.maxQueueSize {
+		last := len(p.queue) - 1
+		if last < 0 || p.queue[last].priority >= priority {
+			p.mu.Unlock()
+			return ErrBulkheadFull
+		}
+		p.queue[last].admitted <- ErrBulkheadFull
+		p.queue = p.queue[:last]
+	}
+	p.seq++
+	waiter := &bulkheadWaiter{priority: priority, seq: p.seq, admitted: make(chan error, 1)}
+	i := sort.Search(len(p.queue), func(i int) bool { return p.queue[i].priority < priority })
+	p.queue = append(p.queue, nil)
+	copy(p.queue[i+1:], p.queue[i:])
+	p.queue[i] = waiter
+	p.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if p.queueTimeout > 0 {
+		timer := time.NewTimer(p.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case err = <-waiter.admitted:
+		return err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrBulkheadTimeout
+	}
+	if !p.remove(waiter) {
+		// the request was admitted or made to fail at the same time
+		if <-waiter.admitted == nil {
+			p.release()
+		}
+	}
+	return err
+}
+
+// This removes the request from the queue, it returns false if the request is no more in the queue.
+func (p *bulkheadPool) remove(waiter *bulkheadWaiter) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.queue {
+		if p.queue[i] == waiter {
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// This records the end of a request in flight, handing over its place to the first request in the queue if any.
+func (p *bulkheadPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) > 0 {
+		waiter := p.queue[0]
+		p.queue = p.queue[1:]
+		waiter.admitted <- nil
+		return
+	}
+	p.inFlight--
+}
+
+// bulkheadClient makes the requests through the bulkhead
+type bulkheadClient struct {
+	name   string
+	client heimdall.Doer
+	pool   *bulkheadPool
+}
+
+// Do is used to make the request once it is admitted by the bulkhead, the request being in flight until its
+// response body is closed
+func (bc *bulkheadClient) Do(req *http.Request) (*http.Response, error) {
+	if err := bc.pool.acquire(req.Context(), getPriority(req.Context())); err != nil {
+		closeRequestBody(req)
+		if errors.Is(err, ErrBulkheadFull) || errors.Is(err, ErrBulkheadTimeout) {
+			return nil, fmt.Errorf("%w for %s", err, bc.name)
+		}
+		return nil, err
+	}
+	response, err := bc.client.Do(req)
+	if response == nil {
+		bc.pool.release()
+		return response, err
+	}
+	response.Body = &releaseOnCloseBody{ReadCloser: response.Body, release: bc.pool.release}
+	return response, err
+}
+
+// releaseOnCloseBody releases the place of the request in flight once the response body is closed,
+// as the connection is in use until then.
+type releaseOnCloseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close is used to close the body and release the place of the request
+func (b *releaseOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,120 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkhead(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, r.URL.Query().Get("name"))
+		mu.Unlock()
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", map[string]interface{}{
+		"method":     http.MethodGet,
+		"url":        server.URL,
+		"retrycount": 1,
+		"bulkhead": map[string]interface{}{
+			"maxconcurrentrequests": 1,
+			"maxqueuesize":          2,
+		},
+	}))
+	pool := client.httpClients["test"].bulkhead
+	waitQueued := func(n int) {
+		for {
+			pool.mu.Lock()
+			queued := len(pool.queue)
+			pool.mu.Unlock()
+			if queued == n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	send := func(name string, priority Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.Request(NewRequest("test").SetQueryParam("name", name).SetPriority(priority))
+			if err == nil {
+				_ = res.Body.Close()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			errs[name] = err
+		}()
+	}
+	send("first", PriorityNormal)
+	for {
+		mu.Lock()
+		started := len(order)
+		mu.Unlock()
+		if started == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	send("background", PriorityBackground)
+	waitQueued(1)
+	send("evicted", PriorityBackground)
+	waitQueued(2)
+
+	// the critical request takes the place of the latest background request, and is made first
+	send("critical", PriorityCritical)
+	for {
+		mu.Lock()
+		evicted := errs["evicted"]
+		mu.Unlock()
+		if evicted != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	require.True(t, errors.Is(errs["evicted"], ErrBulkheadFull))
+	require.NoError(t, errs["first"])
+	require.NoError(t, errs["critical"])
+	require.NoError(t, errs["background"])
+	assert.Equal(t, order, []string{"first", "critical", "background"})
+
+	// the request is in flight until its response body is closed
+	res, err := client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, pool.inFlight, 1)
+	require.NoError(t, res.Body.Close())
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, pool.inFlight, 0)
+
+	pool = newBulkheadPool(NewBulkhead(nil).SetMaxConcurrentRequests(1).SetMaxQueueSize(1).
+		SetQueueTimeout(20 * time.Millisecond))
+	require.NoError(t, pool.acquire(context.Background(), PriorityNormal))
+	require.True(t, errors.Is(pool.acquire(context.Background(), PriorityNormal), ErrBulkheadTimeout))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.True(t, errors.Is(pool.acquire(ctx, PriorityCritical), context.DeadlineExceeded))
+	pool.release()
+	assert.Equal(t, pool.inFlight, 0)
+	assert.Equal(t, len(pool.queue), 0)
+	assert.Equal(t, newBulkheadPool(NewBulkhead(nil)) == nil, true)
+}
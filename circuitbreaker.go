@@ -0,0 +1,436 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gojek/heimdall"
+)
+
+// CircuitState is the state of the circuit breaker of a request
+type CircuitState string
+
+// circuit breaker states
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "halfopen"
+)
+
+// CircuitBreakerMode decides when the circuit breaker of a request opens
+type CircuitBreakerMode string
+
+// supported circuit breaker modes
+const (
+	// CircuitBreakerModeErrorRate opens the circuit when the percentage of the failed requests in the rolling window
+	// reaches the error percent threshold, once there are at least request volume threshold requests in it
+	CircuitBreakerModeErrorRate CircuitBreakerMode = "errorrate"
+	// CircuitBreakerModeConsecutive opens the circuit after the consecutive failures
+	CircuitBreakerModeConsecutive CircuitBreakerMode = "consecutive"
+)
+
+// ErrCircuitOpen is returned when the request is not made as the circuit breaker of the request is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ErrMaxConcurrency is returned when the request is not made as the max concurrent requests are in flight
+var ErrMaxConcurrency = errors.New("max concurrent requests reached")
+
+// ErrServerError is passed to the hystrix fallback for a response with status code 5xx
+var ErrServerError = errors.New("server returned 5xx status code")
+
+// ErrNoCircuitBreaker is returned when the circuit of a request configured without the hystrix config is changed
+var ErrNoCircuitBreaker = errors.New("no circuit breaker configured")
+
+const circuitBuckets = 10
+
+// OnStateChange is used to provide the function called whenever the circuit breaker of a request changes its state
+func (c *Client) OnStateChange(f func(name string, from, to CircuitState)) *Client {
+	if f != nil {
+		c.osc.Do(func() {
+			c.sc = f
+		})
+	}
+	return c
+}
+
+// CircuitState is used to get the state of the circuit breaker of the request
+// It is always closed for the requests configured without the hystrix config
+func (c *Client) CircuitState(name string) (CircuitState, error) {
+	client, ok := c.getClientRequestMapping(name)
+	if !ok {
+		return "", &UnknownRequestError{Name: name, Configured: c.Names()}
+	}
+	if client.breaker == nil {
+		return CircuitClosed, nil
+	}
+	return client.breaker.getState(time.Now()), nil
+}
+
+// ForceCircuitOpen is used to fail all the requests with the name until the circuit is reset
+func (c *Client) ForceCircuitOpen(name string) error {
+	breaker, err := c.getCircuitBreaker(name)
+	if err != nil {
+		return err
+	}
+	breaker.force(time.Now(), CircuitOpen)
+	return nil
+}
+
+// ForceCircuitClosed is used to make all the requests with the name, however they fail, until the circuit is reset
+func (c *Client) ForceCircuitClosed(name string) error {
+	breaker, err := c.getCircuitBreaker(name)
+	if err != nil {
+		return err
+	}
+	breaker.force(time.Now(), CircuitClosed)
+	return nil
+}
+
+// ResetCircuit is used to close the circuit of the requests with the name, which opens again as per their failures
+func (c *Client) ResetCircuit(name string) error {
+	breaker, err := c.getCircuitBreaker(name)
+	if err != nil {
+		return err
+	}
+	breaker.reset()
+	return nil
+}
+
+func (c *Client) getCircuitBreaker(name string) (*circuitBreaker, error) {
+	client, ok := c.getClientRequestMapping(name)
+	if !ok {
+		return nil, &UnknownRequestError{Name: name, Configured: c.Names()}
+	}
+	if client.breaker == nil {
+		return nil, fmt.Errorf("%w for %s", ErrNoCircuitBreaker, name)
+	}
+	return client.breaker, nil
+}
+
+// This reports the change of the state of the circuit breaker of the request using the logger, metrics and callback.
+// The metric has the MetricKindCircuitState kind, to tell it apart from the metrics of the requests.
+func (c *Client) circuitStateChanged(name string, from, to CircuitState) {
+	ctx := context.Background()
+	c.log(ctx, fmt.Sprintf("Circuit breaker of http request %s changed from %s to %s", name, from, to))
+	if c.m != nil {
+		c.m(ctx, name, Metric{Kind: MetricKindCircuitState, CircuitState: to, PreviousCircuitState: from})
+	}
+	if c.sc != nil {
+		c.sc(name, from, to)
+	}
+}
+
+// circuitBucket counts the requests and the failures in a part of the rolling window
+type circuitBucket struct {
+	epoch    int64
+	requests int
+	failures int
+}
+
+// circuitBreaker tracks the health of the requests with a name, and stops making them when they keep failing.
+// It is scoped to the Client, unlike the hystrix commands which are shared by the whole process.
+type circuitBreaker struct {
+	mode                   CircuitBreakerMode
+	errorPercentThreshold  int
+	requestVolumeThreshold int
+	consecutiveFailures    int
+	halfOpenProbes         int
+	sleepWindow            time.Duration
+	bucketSize             time.Duration
+	notify                 func(from, to CircuitState)
+	slots                  chan struct{}
+
+	mu             sync.Mutex
+	state          CircuitState
+	forced         bool
+	openedAt       time.Time
+	failures       int
+	buckets        [circuitBuckets]circuitBucket
+	probes         int
+	probeSuccesses int
+}
+
+// This creates the circuit breaker for the request config, which is nil if there is no hystrix config.
+// The notify function is called on every change of the state.
+func newCircuitBreaker(requestConfig *RequestConfig, notify func(from, to CircuitState)) *circuitBreaker {
+	hc := requestConfig.hystrixConfig
+	if hc == nil {
+		return nil
+	}
+	cb := &circuitBreaker{
+		mode:                   hc.mode,
+		errorPercentThreshold:  hc.errorPercentThreshold,
+		requestVolumeThreshold: hc.requestVolumeThreshold,
+		consecutiveFailures:    hc.consecutiveFailures,
+		halfOpenProbes:         hc.halfOpenProbes,
+		sleepWindow:            time.Duration(hc.sleepWindowInMillis) * time.Millisecond,
+		bucketSize:             time.Duration(hc.rollingWindowInMillis) * time.Millisecond / circuitBuckets,
+		notify:                 notify,
+		state:                  CircuitClosed,
+	}
+	if cb.mode == "" {
+		cb.mode = CircuitBreakerModeErrorRate
+	}
+	if cb.errorPercentThreshold <= 0 {
+		cb.errorPercentThreshold = defaultErrorPercentThreshold
+	}
+	if cb.requestVolumeThreshold <= 0 {
+		cb.requestVolumeThreshold = defaultRequestVolumeThreshold
+	}
+	if cb.consecutiveFailures <= 0 {
+		cb.consecutiveFailures = defaultConsecutiveFailures
+	}
+	if cb.halfOpenProbes <= 0 {
+		cb.halfOpenProbes = defaultHalfOpenProbes
+	}
+	if cb.bucketSize <= 0 {
+		cb.bucketSize = time.Duration(defaultRollingWindowInMillis) * time.Millisecond / circuitBuckets
+	}
+	maxConcurrentRequests := hc.maxConcurrentRequests
+	if maxConcurrentRequests <= 0 {
+		maxConcurrentRequests = defaultMaxConcurrentRequests
+	}
+	cb.slots = make(chan struct{}, maxConcurrentRequests)
+	return cb
+}
+
+// This runs the function holding the lock, and notifies the change of the state after releasing it.
+func (cb *circuitBreaker) update(f func()) {
+	cb.mu.Lock()
+	from := cb.state
+	f()
+	to := cb.state
+	cb.mu.Unlock()
+
+	if from != to && cb.notify != nil {
+		cb.notify(from, to)
+	}
+}
+
+// This gets the current state, the open circuit is half open once the sleep window is over.
+func (cb *circuitBreaker) getState(now time.Time) CircuitState {
+	var state CircuitState
+	cb.update(func() {
+		cb.refresh(now)
+		state = cb.state
+	})
+	return state
+}
+
+func (cb *circuitBreaker) refresh(now time.Time) {
+	if cb.state == CircuitOpen && !cb.forced && now.Sub(cb.openedAt) >= cb.sleepWindow {
+		cb.state = CircuitHalfOpen
+		cb.probes, cb.probeSuccesses = 0, 0
+	}
+}
+
+// This checks whether a request can be made, it returns whether the request is a probe of the half open circuit.
+func (cb *circuitBreaker) allow(now time.Time) (bool, error) {
+	var probe bool
+	var err error
+	cb.update(func() {
+		cb.refresh(now)
+		switch cb.state {
+		case CircuitOpen:
+			err = ErrCircuitOpen
+		case CircuitHalfOpen:
+			if cb.probes >= cb.halfOpenProbes {
+				err = ErrCircuitOpen
+				return
+			}
+			cb.probes++
+			probe = true
+		}
+	})
+	return probe, err
+}
+
+// This records the outcome of a request allowed by the circuit breaker.
+func (cb *circuitBreaker) done(now time.Time, probe bool, failed bool) {
+	cb.update(func() {
+		if cb.forced {
+			return
+		}
+		if probe {
+			if cb.state != CircuitHalfOpen {
+				return
+			}
+			if failed {
+				cb.open(now)
+				return
+			}
+			cb.probeSuccesses++
+			if cb.probeSuccesses >= cb.halfOpenProbes {
+				cb.close()
+			}
+			return
+		}
+		if cb.state != CircuitClosed {
+			return
+		}
+
+		if cb.mode == CircuitBreakerModeConsecutive {
+			if !failed {
+				cb.failures = 0
+				return
+			}
+			cb.failures++
+			if cb.failures >= cb.consecutiveFailures {
+				cb.open(now)
+			}
+			return
+		}
+
+		bucket := cb.bucket(now)
+		bucket.requests++
+		if failed {
+			bucket.failures++
+		}
+		var requests, failures int
+		for i := range cb.buckets {
+			if cb.buckets[i].epoch > bucket.epoch-circuitBuckets {
+				requests += cb.buckets[i].requests
+				failures += cb.buckets[i].failures
+			}
+		}
+		if requests >= cb.requestVolumeThreshold && failures*100 >= cb.errorPercentThreshold*requests {
+			cb.open(now)
+		}
+	})
+}
+
+// This gives back the probe of a request which was allowed but not made.
+func (cb *circuitBreaker) release(probe bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if probe && cb.state == CircuitHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+// This keeps the circuit in the state until it is reset, irrespective of the outcome of the requests.
+func (cb *circuitBreaker) force(now time.Time, state CircuitState) {
+	cb.update(func() {
+		if state == CircuitOpen {
+			cb.open(now)
+		} else {
+			cb.close()
+		}
+		cb.forced = true
+	})
+}
+
+// This closes the circuit, tracking the outcome of the requests again.
+func (cb *circuitBreaker) reset() {
+	cb.update(func() {
+		cb.close()
+		cb.forced = false
+	})
+}
+
+func (cb *circuitBreaker) open(now time.Time) {
+	cb.state = CircuitOpen
+	cb.openedAt = now
+}
+
+func (cb *circuitBreaker) close() {
+	cb.state = CircuitClosed
+	cb.failures = 0
+	cb.buckets = [circuitBuckets]circuitBucket{}
+}
+
+// This gets the bucket for the time, resetting it if it belongs to an older window.
+func (cb *circuitBreaker) bucket(now time.Time) *circuitBucket {
+	epoch := now.UnixNano() / int64(cb.bucketSize)
+	bucket := &cb.buckets[epoch%circuitBuckets]
+	if bucket.epoch != epoch {
+		*bucket = circuitBucket{epoch: epoch}
+	}
+	return bucket
+}
+
+// circuitBreakerClient makes the requests through the circuit breaker, bounding every request by the hystrix timeout
+// and the number of requests in flight by the max concurrent requests
+type circuitBreakerClient struct {
+	name     string
+	client   heimdall.Doer
+	breaker  *circuitBreaker
+	timeout  time.Duration
+	fallback func(error) error
+}
+
+// This creates the client making the requests through the circuit breaker.
+func newCircuitBreakerClient(requestConfig *RequestConfig, client heimdall.Doer,
+	breaker *circuitBreaker) *circuitBreakerClient {
+	cbc := &circuitBreakerClient{
+		name:     requestConfig.name,
+		client:   client,
+		breaker:  breaker,
+		timeout:  requestConfig.hystrixConfig.hystrixTimeout,
+		fallback: requestConfig.hystrixConfig.fallback,
+	}
+	if cbc.timeout <= 0 {
+		cbc.timeout = defaultHystrixTimeout
+	}
+	return cbc
+}
+
+// Do is used to make the request through the circuit breaker
+// The fallback, if set, is called with the error when the request fails or is not made, and its error is returned.
+// It is called with an error matching ErrServerError for a response with status code 5xx, and if it returns an error,
+// the response is discarded and the error is returned instead
+func (cbc *circuitBreakerClient) Do(req *http.Request) (*http.Response, error) {
+	response, err := cbc.do(req)
+	if cbc.fallback == nil {
+		return response, err
+	}
+	if err != nil {
+		return response, cbc.fallback(err)
+	}
+	if response.StatusCode >= http.StatusInternalServerError {
+		if err = cbc.fallback(fmt.Errorf("%w for %s", ErrServerError, cbc.name)); err != nil {
+			_, _ = io.Copy(ioutil.Discard, response.Body)
+			_ = response.Body.Close()
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+func (cbc *circuitBreakerClient) do(req *http.Request) (*http.Response, error) {
+	probe, err := cbc.breaker.allow(time.Now())
+	if err != nil {
+		closeRequestBody(req)
+		return nil, fmt.Errorf("%w for %s", err, cbc.name)
+	}
+	select {
+	case cbc.breaker.slots <- struct{}{}:
+		defer func() { <-cbc.breaker.slots }()
+	default:
+		cbc.breaker.release(probe)
+		closeRequestBody(req)
+		return nil, fmt.Errorf("%w for %s", ErrMaxConcurrency, cbc.name)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), cbc.timeout)
+	response, err := cbc.client.Do(req.WithContext(ctx))
+	if errors.Is(err, context.Canceled) {
+		// the request was cancelled by the caller or lost to a hedge, which says nothing about the endpoint
+		cbc.breaker.release(probe)
+	} else {
+		cbc.breaker.done(time.Now(), probe, err != nil || response.StatusCode >= http.StatusInternalServerError)
+	}
+	if response == nil {
+		cancel()
+		return nil, err
+	}
+	response.Body = &cancelOnCloseBody{ReadCloser: response.Body, cancel: cancel}
+	return response, err
+}
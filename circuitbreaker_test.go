@@ -0,0 +1,259 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var attempts int32
+	var healthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	requestConfig := func() *RequestConfig {
+		return NewRequestConfig("test", map[string]interface{}{
+			"method":     http.MethodGet,
+			"url":        server.URL,
+			"retrycount": 0,
+			"hystrixconfig": map[string]interface{}{
+				"mode":                "consecutive",
+				"consecutivefailures": 3,
+				"halfopenprobes":      2,
+				"sleepwindowinmillis": 50,
+			},
+		})
+	}
+	client := ConfigureHTTPClient(requestConfig())
+	other := ConfigureHTTPClient(requestConfig())
+
+	for i := 0; i < 3; i++ {
+		res, err := client.Request(NewRequest("test"))
+		require.NoError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusInternalServerError)
+		require.NoError(t, res.Body.Close())
+	}
+	_, err := client.Request(NewRequest("test").SetRetryCount(2))
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(3))
+
+	// the circuit breaker is not shared by the clients
+	res, err := other.Request(NewRequest("test"))
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(4))
+
+	// once the sleep window is over, the probes close the circuit if they succeed
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		res, err := client.Request(NewRequest("test"))
+		require.NoError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		require.NoError(t, res.Body.Close())
+	}
+
+	breaker := newCircuitBreaker(NewRequestConfig("rate", nil).SetHystrixConfig(NewHystrixConfig(nil).
+		SetErrorPercentThreshold(50).SetRequestVolumeThreshold(4).SetSleepWindowInMillis(50)), nil)
+	now := time.Now()
+	for _, failed := range []bool{false, true, false} {
+		probe, err := breaker.allow(now)
+		require.NoError(t, err)
+		breaker.done(now, probe, failed)
+	}
+	assert.Equal(t, breaker.state, CircuitClosed)
+	breaker.done(now, false, true)
+	assert.Equal(t, breaker.state, CircuitOpen)
+
+	// a failed probe opens the circuit again
+	probe, err := breaker.allow(now.Add(60 * time.Millisecond))
+	require.NoError(t, err)
+	require.True(t, probe)
+	_, err = breaker.allow(now.Add(60 * time.Millisecond))
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+	breaker.done(now.Add(70*time.Millisecond), probe, true)
+	assert.Equal(t, breaker.state, CircuitOpen)
+	_, err = breaker.allow(now.Add(100 * time.Millisecond))
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+
+	// the timeout and the max concurrent requests default to the ones of hystrix
+	hystrixRequestConfig := NewRequestConfig("test", nil).SetHystrixConfig(NewHystrixConfig(nil))
+	cbc := newCircuitBreakerClient(hystrixRequestConfig, nil, newCircuitBreaker(hystrixRequestConfig, nil))
+	assert.Equal(t, cbc.timeout, time.Second)
+	assert.Equal(t, cap(cbc.breaker.slots), 10)
+}
+
+func TestCircuitBreakerWithHedging(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1)%2 == 1 {
+			// the first request of every pair is slow, and is cancelled once the hedge wins
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", map[string]interface{}{
+		"method":     http.MethodGet,
+		"url":        server.URL,
+		"retrycount": 0,
+		"hedging": map[string]interface{}{
+			"delayinmillis": 10,
+			"maxhedges":     1,
+		},
+		"hystrixconfig": map[string]interface{}{
+			"errorpercentthreshold":  50,
+			"requestvolumethreshold": 4,
+		},
+	}))
+
+	// the cancelled requests are not failures of the endpoint
+	for i := 0; i < 6; i++ {
+		res, err := client.Request(NewRequest("test"))
+		require.NoError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		require.NoError(t, res.Body.Close())
+	}
+	state, err := client.CircuitState("test")
+	require.NoError(t, err)
+	assert.Equal(t, state, CircuitClosed)
+}
+
+func TestHystrixFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	errUnavailable := errors.New("unavailable")
+	var fallbackErrs []error
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetRetryCount(0).SetHystrixConfig(NewHystrixConfig(nil)).SetHystrixFallback(func(err error) error {
+		fallbackErrs = append(fallbackErrs, err)
+		return errUnavailable
+	}), NewRequestConfig("ignored", nil).SetMethod(http.MethodGet).SetURL(server.URL).SetRetryCount(0).
+		SetHystrixConfig(NewHystrixConfig(nil)).SetHystrixFallback(func(err error) error {
+		return nil
+	}))
+
+	// the fallback is called for the responses with status code 5xx, and its error replaces the response
+	res, err := client.Request(NewRequest("test"))
+	require.True(t, errors.Is(err, errUnavailable))
+	require.Nil(t, res)
+	require.Len(t, fallbackErrs, 1)
+	require.True(t, errors.Is(fallbackErrs[0], ErrServerError))
+
+	// the response is returned as is when the fallback does not return an error
+	res, err = client.Request(NewRequest("ignored"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusServiceUnavailable)
+	require.NoError(t, res.Body.Close())
+}
+
+func TestCircuitState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var changes, logs []string
+	var metrics []Metric
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetHystrixConfig(NewHystrixConfig(nil).SetMode(CircuitBreakerModeConsecutive).SetConsecutiveFailures(1).
+			SetSleepWindowInMillis(20)), NewRequestConfig("plain", nil).SetURL(server.URL)).
+		OnStateChange(func(name string, from, to CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, fmt.Sprintf("%s:%s->%s", name, from, to))
+		}).
+		WithLogger(func(ctx context.Context, msg string) {
+			mu.Lock()
+			defer mu.Unlock()
+			logs = append(logs, msg)
+		}).
+		WithMetrics(func(ctx context.Context, name string, m Metric) {
+			mu.Lock()
+			defer mu.Unlock()
+			metrics = append(metrics, m)
+		})
+
+	state, err := client.CircuitState("test")
+	require.NoError(t, err)
+	assert.Equal(t, state, CircuitClosed)
+	_, err = client.CircuitState("unknown")
+	require.True(t, errors.Is(err, ErrUnknownRequest))
+	require.True(t, errors.Is(client.ForceCircuitOpen("plain"), ErrNoCircuitBreaker))
+
+	res, err := client.Request(NewRequest("test").DisableRetries())
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	state, err = client.CircuitState("test")
+	require.NoError(t, err)
+	assert.Equal(t, state, CircuitOpen)
+	time.Sleep(30 * time.Millisecond)
+	state, err = client.CircuitState("test")
+	require.NoError(t, err)
+	assert.Equal(t, state, CircuitHalfOpen)
+
+	// the forced state does not change with the outcome of the requests or the sleep window
+	require.NoError(t, client.ForceCircuitClosed("test"))
+	res, err = client.Request(NewRequest("test").DisableRetries())
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	state, _ = client.CircuitState("test")
+	assert.Equal(t, state, CircuitClosed)
+
+	require.NoError(t, client.ForceCircuitOpen("test"))
+	time.Sleep(30 * time.Millisecond)
+	_, err = client.Request(NewRequest("test"))
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+
+	require.NoError(t, client.ResetCircuit("test"))
+	res, err = client.Request(NewRequest("test").DisableRetries())
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	state, _ = client.CircuitState("test")
+	assert.Equal(t, state, CircuitOpen)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, changes, []string{"test:closed->open", "test:open->halfopen", "test:halfopen->closed",
+		"test:closed->open", "test:open->closed", "test:closed->open"})
+	require.Contains(t, logs, "Circuit breaker of http request test changed from closed to open")
+	var requests int
+	var transitions []CircuitState
+	for _, m := range metrics {
+		switch m.Kind {
+		case MetricKindRequest:
+			requests++
+		case MetricKindCircuitState:
+			transitions = append(transitions, m.CircuitState)
+		}
+	}
+	assert.Equal(t, requests, 3)
+	assert.Equal(t, transitions, []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed, CircuitOpen,
+		CircuitClosed, CircuitOpen})
+}
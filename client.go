@@ -9,12 +9,12 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gojek/heimdall"
-	"github.com/gojek/heimdall/httpclient"
-	"github.com/gojek/heimdall/hystrix"
 	"github.com/google/uuid"
 	"golang.org/x/net/publicsuffix"
 )
@@ -22,10 +22,30 @@ import (
 // Logger is the logger used to log the http traces
 type Logger func(ctx context.Context, msg string)
 
+// MetricKind tells what a metric is reported for
+type MetricKind string
+
+// kinds of the metrics
+const (
+	MetricKindRequest      MetricKind = "request"
+	MetricKindCircuitState MetricKind = "circuitState"
+)
+
 // Metric is the information used to tracking the performance
 type Metric struct {
-	Status          int   `json:"status"`
-	LatencyInMillis int64 `json:"latency"`
+	Kind              MetricKind `json:"kind"`
+	Status            int        `json:"status"`
+	LatencyInMillis   int64      `json:"latency"`
+	Retries           int        `json:"retries"`
+	RetriesSuppressed bool       `json:"retriesSuppressed"`
+	Hedges            int        `json:"hedges"`
+	HedgeWon          bool       `json:"hedgeWon"`
+	Fallback          bool       `json:"fallback"`
+	ConcurrencyLimit  int        `json:"concurrencyLimit"`
+
+	// CircuitState and PreviousCircuitState are set only for the metrics of the MetricKindCircuitState kind
+	CircuitState         CircuitState `json:"circuitState,omitempty"`
+	PreviousCircuitState CircuitState `json:"previousCircuitState,omitempty"`
 }
 
 // Metrics provides the basic information for status and latency
@@ -33,6 +53,7 @@ type Metrics func(ctx context.Context, name string, m Metric)
 
 // Client is the http client
 type Client struct {
+	mu          sync.RWMutex
 	httpClients map[string]ClientRequestMapping
 
 	ol sync.Once
@@ -40,36 +61,110 @@ type Client struct {
 
 	om sync.Once
 	m  Metrics
+
+	ofm sync.Once
+	fm  bool
+
+	osc sync.Once
+	sc  func(name string, from, to CircuitState)
+
+	rlm          sync.Mutex
+	rateLimiters map[string]*RateLimiter
 }
 
-// ClientRequestMapping provides a container for heimdall client and associated RequestConfig.
+// ErrUnknownRequest is matched using errors.Is by the error returned for a request name which is not configured
+var ErrUnknownRequest = errors.New("unknown request")
+
+// UnknownRequestError is the error returned for a request name which is not configured
+type UnknownRequestError struct {
+	Name       string
+	Configured []string
+}
+
+// Error is used to get the error message
+func (e *UnknownRequestError) Error() string {
+	return fmt.Sprintf("unknown request %s, configured requests are [%s]", e.Name, strings.Join(e.Configured, ", "))
+}
+
+// Is is used to match the error with ErrUnknownRequest
+func (e *UnknownRequestError) Is(target error) bool {
+	return target == ErrUnknownRequest
+}
+
+// ClientRequestMapping provides a container for http or circuit breaker client and associated RequestConfig.
 type ClientRequestMapping struct {
-	heimdallClient heimdall.Client
-	requestConfig  *RequestConfig
+	doer          heimdall.Doer
+	requestConfig *RequestConfig
+	retryBudget   *retryBudgetTracker
+	latencies     *latencyTracker
+	breaker       *circuitBreaker
+	concurrency   *concurrencyLimiter
+	bulkhead      *bulkheadPool
+	rateLimiter   *RateLimiter
+	balancer      *endpointBalancer
 }
 
 // ConfigureHTTPClient receives RequestConfigs and initializes one http client per RequestConfig.
-// It creates heimdall http or hystrix client based on the configuration provided in RequestConfig.
+// It creates http or circuit breaker client based on the configuration provided in RequestConfig.
 // Returns the instance of Client
 func ConfigureHTTPClient(requestConfigs ...*RequestConfig) *Client {
-	httpClients := make(map[string]ClientRequestMapping)
+	client := Client{}
+	client.httpClients = make(map[string]ClientRequestMapping)
+	client.addClientRequestMappings(client.httpClients, nil, requestConfigs)
 
+	return &client
+}
+
+// This builds the heimdall client for every RequestConfig and adds it to the mappings, replacing the existing one.
+// The state of the previous mapping with the same name is kept for the settings which did not change.
+func (c *Client) addClientRequestMappings(httpClients, previous map[string]ClientRequestMapping,
+	requestConfigs []*RequestConfig) {
 	for _, requestConfig := range requestConfigs {
 		if requestConfig != nil {
+			name := requestConfig.name
+			breaker := newCircuitBreaker(requestConfig, func(from, to CircuitState) {
+				c.circuitStateChanged(name, from, to)
+			})
+			balancer := newEndpointBalancer(requestConfig, func(url string) {
+				c.log(context.Background(), fmt.Sprintf("Ejected endpoint %s of http request %s", url, name))
+			})
 			clientRequestMapping :=
 				ClientRequestMapping{
-					heimdallClient: buildHTTPClient(requestConfig),
-					requestConfig:  requestConfig,
+					requestConfig: requestConfig,
+					balancer:      balancer,
+					breaker:       breaker,
+					concurrency:   newConcurrencyLimiter(requestConfig.concurrencyLimit),
+					bulkhead:      newBulkheadPool(requestConfig.bulkhead),
+					rateLimiter:   c.getRateLimiter(requestConfig),
+					retryBudget:   newRetryBudgetTracker(requestConfig.retryBudget),
+					latencies:     newLatencyTracker(requestConfig),
 				}
+			if previousMapping, ok := previous[name]; ok {
+				clientRequestMapping.keepState(previousMapping)
+			}
+			clientRequestMapping.doer = buildHTTPClient(clientRequestMapping)
 			httpClients[requestConfig.name] = clientRequestMapping
 		}
 	}
+}
 
-	client := Client{
-		httpClients: httpClients,
+// This keeps the state of the previous mapping, like the requests in flight, the open circuit and the retries made,
+// for the settings which did not change, so that reloading the configuration does not reset them.
+func (client *ClientRequestMapping) keepState(previous ClientRequestMapping) {
+	current, last := client.requestConfig, previous.requestConfig
+	if current.bulkhead != nil && last.bulkhead != nil && *current.bulkhead == *last.bulkhead {
+		client.bulkhead = previous.bulkhead
+	}
+	if current.concurrencyLimit != nil && last.concurrencyLimit != nil &&
+		*current.concurrencyLimit == *last.concurrencyLimit {
+		client.concurrency = previous.concurrency
+	}
+	if current.retryBudget != nil && last.retryBudget != nil && *current.retryBudget == *last.retryBudget {
+		client.retryBudget = previous.retryBudget
+	}
+	if current.hystrixConfig.sameCircuit(last.hystrixConfig) {
+		client.breaker = previous.breaker
 	}
-
-	return &client
 }
 
 // WithLogger is used to provide the logger instance for the http client created
@@ -92,51 +187,164 @@ func (c *Client) WithMetrics(m Metrics) *Client {
 	return c
 }
 
+// WithFailedRequestMetrics is used to report the metrics of the requests which failed without a response as well,
+// with the status 0. If not done, then among those only the metrics of the ones whose retries were suppressed by the
+// retry budget are reported
+func (c *Client) WithFailedRequestMetrics() *Client {
+	c.ofm.Do(func() {
+		c.fm = true
+	})
+	return c
+}
+
+// Has is used to check whether a request with the given name is configured
+func (c *Client) Has(name string) bool {
+	_, ok := c.getClientRequestMapping(name)
+	return ok
+}
+
+// Names is used to get the names of all the configured requests in sorted order
+func (c *Client) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.httpClients))
+	for name := range c.httpClients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Request receives Request param to execute. It will fetch the right http client for given Request name
 // and use it to execute based on attributes provided in Request
-// It returns http.Response and error
+// It returns http.Response and error, which is an UnknownRequestError if the request name is not configured
+// and a StatusError if the status code of the response is an error as per the RequestConfig
+// If the request fails after all the retries, the response and error of the fallback, if configured, are returned
 func (c *Client) Request(request *Request) (*http.Response, error) {
-	client := c.httpClients[request.name]
+	client, ok := c.getClientRequestMapping(request.name)
+	if !ok {
+		return nil, &UnknownRequestError{Name: request.name, Configured: c.Names()}
+	}
 
 	// set the method and url using the initial config
-	if request.method == "" {
-		request.method = client.requestConfig.method
+	method := request.method
+	if method == "" {
+		method = client.requestConfig.method
+	}
+	// with multiple endpoints, the url has only the path and query, and the endpoint is picked for every attempt
+	baseURL := request.url
+	if baseURL == "" && client.balancer == nil {
+		baseURL = client.requestConfig.url
 	}
-	if request.url == "" {
-		request.url = client.requestConfig.url
+	path := request.path
+	if path == "" {
+		path = client.requestConfig.path
 	}
-	// append static headers if exists
-	if request.headerParams == nil {
-		request.headerParams = client.requestConfig.headers
+	requestURL, err := getRequestURL(baseURL, path, request.pathParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// merge the static headers with the request headers
+	headers := getHeaders(client.requestConfig.headers, request.headerParams, request.removedHeaders)
+
+	// fill the request-id header for log tracing, unless the request has set or removed it
+	requestIDKey := http.CanonicalHeaderKey(requestIDHeader)
+	if _, ok := request.headerParams[requestIDKey]; !ok && !request.removedHeaders[requestIDKey] {
+		headers.Set(requestIDHeader, getRequestID(request.ctx))
 	}
 
-	// fill the request-id header for log tracing
-	request.SetHeaderParam(requestIDHeader, getRequestID(request.ctx))
+	// fill the idempotency key shared by all the attempts, so that retrying non idempotent requests is safe
+	if client.requestConfig.retryPolicy.needsIdempotencyKey(method) && headers.Get(idempotencyKeyHeader) == "" {
+		headers.Set(idempotencyKeyHeader, uuid.NewString())
+	}
 
 	// start the timer
 	start := time.Now()
 
+	body, err := getRequestBody(request)
+	if err != nil {
+		return nil, err
+	}
+
 	// get the http request
-	req, err := getRequest(request.ctx, request.method, request.url, request.queryParams,
-		request.headerParams, request.body)
+	req, err := getRequest(request.ctx, method, requestURL, request.queryParams, headers, body)
 	if err != nil {
 		return nil, err
 	}
+	if request.priority != PriorityNormal {
+		req = req.WithContext(context.WithValue(req.Context(), priorityKey{}, request.priority))
+	}
+	if client.balancer != nil {
+		req = req.WithContext(context.WithValue(req.Context(), triedEndpointsKey{}, &triedEndpoints{}))
+	}
 
 	// now perform the request
-	response, err := client.heimdallClient.Do(req)
+	var metric Metric
+	response, err := c.execute(client, request, req, &metric)
+	// classify the status first, so that the fallback is called for the responses which failed as well
+	var statusErr *StatusError
+	if err == nil && response != nil && client.isFailedStatus(response.StatusCode) {
+		statusErr = newStatusError(request.name, response)
+		_ = response.Body.Close()
+	}
+	if (err != nil || statusErr != nil) && client.requestConfig.fallback != nil {
+		// serve the response of the fallback instead, if any
+		fallbackErr := err
+		if statusErr != nil {
+			fallbackErr = statusErr
+			statusErr = nil
+		}
+		response, err = client.requestConfig.fallback(req.Context(), request, fallbackErr)
+		// the metric tells whether the request was served by the fallback, not only whether it was called
+		metric.Fallback = err == nil && response != nil
+		if response != nil && response.Request == nil {
+			response.Request = req
+		}
+	}
 	if err == nil && response == nil {
 		return nil, errors.New("unable to fetch response")
 	}
 	if err == nil {
 		// end the timer and log latency and status code
 		c.logLatencyAndStatusCode(request, start, response.StatusCode)
-		c.metricLatencyAndStatusCode(request, start, response.StatusCode)
+		metric.Status = response.StatusCode
+	}
+	if err == nil || c.fm || metric.RetriesSuppressed {
+		metric.ConcurrencyLimit = client.concurrency.getLimit()
+		c.metricLatencyAndStatusCode(request, start, metric)
+	}
+	if statusErr != nil {
+		return response, statusErr
 	}
 
 	return response, err
 }
 
+// This checks whether the status code is an error as per the config. With a fallback, the 5xx status codes left after
+// all the retries are errors as well, so that the fallback is called for them.
+func (client ClientRequestMapping) isFailedStatus(statusCode int) bool {
+	if client.requestConfig.errorOnStatus != nil && client.requestConfig.errorOnStatus(statusCode) {
+		return true
+	}
+	return client.requestConfig.fallback != nil && statusCode >= http.StatusInternalServerError
+}
+
+func (c *Client) getClientRequestMapping(name string) (ClientRequestMapping, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	client, ok := c.httpClients[name]
+	return client, ok
+}
+
+func (c *Client) log(ctx context.Context, msg string) {
+	if c.l != nil {
+		c.l(ctx, msg)
+	}
+}
+
 func (c *Client) logLatencyAndStatusCode(request *Request, start time.Time, statusCode int) {
 	if c.l != nil {
 		c.l(request.ctx, fmt.Sprintf("Fulfilled http request %s with status %d in duration %d ms",
@@ -144,9 +352,11 @@ func (c *Client) logLatencyAndStatusCode(request *Request, start time.Time, stat
 	}
 }
 
-func (c *Client) metricLatencyAndStatusCode(request *Request, start time.Time, statusCode int) {
+func (c *Client) metricLatencyAndStatusCode(request *Request, start time.Time, metric Metric) {
 	if c.m != nil {
-		c.m(request.ctx, request.name, Metric{Status: statusCode, LatencyInMillis: time.Now().Sub(start).Milliseconds()})
+		metric.Kind = MetricKindRequest
+		metric.LatencyInMillis = time.Now().Sub(start).Milliseconds()
+		c.m(request.ctx, request.name, metric)
 	}
 }
 
@@ -163,8 +373,8 @@ func getRequestID(ctx context.Context) string {
 }
 
 // This is an internal method to form the http.Request based on various parameters.
-func getRequest(ctx context.Context, method string, url string, queryParams map[string]string,
-	headerParams map[string]string, body io.Reader) (*http.Request, error) {
+func getRequest(ctx context.Context, method string, url string, queryParams url.Values,
+	headers http.Header, body io.Reader) (*http.Request, error) {
 	request, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
@@ -176,50 +386,70 @@ func getRequest(ctx context.Context, method string, url string, queryParams map[
 
 	if queryParams != nil {
 		q := request.URL.Query()
-		for k, v := range queryParams {
-			q.Add(k, v)
+		for k, values := range queryParams {
+			for _, v := range values {
+				q.Add(k, v)
+			}
 		}
 		request.URL.RawQuery = q.Encode()
 	}
 
-	for k, v := range headerParams {
-		request.Header.Add(k, v)
-	}
+	request.Header = headers
 
 	return request, err
 }
 
-// Internal method to build http or hystrix client based on settings provided in RequestConfig.
-// It will create hystrix client if hystrixConfig is provided else it will provide httpclient.
-func buildHTTPClient(requestConfig *RequestConfig) heimdall.Client {
-	if requestConfig.hystrixConfig == nil {
-		httpClient := httpclient.NewClient(
-			httpclient.WithHTTPClient(getClient(requestConfig)),
-			httpclient.WithHTTPTimeout(requestConfig.timeout),
-			httpclient.WithRetryCount(requestConfig.retryCount),
-			httpclient.WithRetrier(getRetrier(requestConfig)),
-		)
-		return httpClient
-	} else {
-		hystixClient := hystrix.NewClient(
-			hystrix.WithHTTPClient(getClient(requestConfig)),
-			hystrix.WithCommandName(requestConfig.name),
-			hystrix.WithHTTPTimeout(requestConfig.timeout),
-			hystrix.WithRetryCount(requestConfig.retryCount),
-			hystrix.WithRetrier(getRetrier(requestConfig)),
-			hystrix.WithHystrixTimeout(requestConfig.hystrixConfig.hystrixTimeout),
-			hystrix.WithMaxConcurrentRequests(requestConfig.hystrixConfig.maxConcurrentRequests),
-			hystrix.WithErrorPercentThreshold(requestConfig.hystrixConfig.errorPercentThreshold),
-			hystrix.WithSleepWindow(requestConfig.hystrixConfig.sleepWindowInMillis),
-			hystrix.WithRequestVolumeThreshold(requestConfig.hystrixConfig.requestVolumeThreshold),
-			hystrix.WithFallbackFunc(requestConfig.hystrixConfig.fallback),
-		)
-
-		return hystixClient
+// This merges the static headers of the RequestConfig with the headers of the Request into a new http.Header,
+// so that neither of them is ever modified while making the request.
+// The static headers are the defaults, the headers removed from the Request are dropped from them
+// and the headers set in the Request replace the ones with the same name.
+func getHeaders(staticHeaders map[string]string, headerParams http.Header,
+	removedHeaders map[string]bool) http.Header {
+	headers := make(http.Header, len(staticHeaders)+len(headerParams))
+	for k, v := range staticHeaders {
+		headers.Set(k, v)
+	}
+	for k := range removedHeaders {
+		headers.Del(k)
 	}
+	for k, values := range headerParams {
+		headers[http.CanonicalHeaderKey(k)] = append([]string(nil), values...)
+	}
+	return headers
+}
+
+// Internal method to build http or circuit breaker client based on settings provided in RequestConfig.
+// It will make the requests through the circuit breaker of the mapping if hystrixConfig is provided
+// else it will provide the http client.
+// The clients make a single attempt, the retries are done by the Client so that they can be overridden per Request,
+// and the errors are returned as is, so that the RetryPolicy can tell them apart.
+// With multiple urls, every request is made to the endpoint picked by the balancer of the mapping.
+// Every request, including the retries and hedges, is made within the rate limit, then through the bulkhead and
+// then within the concurrency limit if they are provided, so that the requests waiting are not counted as in flight.
+func buildHTTPClient(clientRequestMapping ClientRequestMapping) heimdall.Doer {
+	requestConfig := clientRequestMapping.requestConfig
+	client := getClient(requestConfig)
+	if clientRequestMapping.balancer != nil {
+		client = &loadBalancedClient{client: client, balancer: clientRequestMapping.balancer}
+	}
+	if clientRequestMapping.breaker != nil {
+		client = newCircuitBreakerClient(requestConfig, client, clientRequestMapping.breaker)
+	}
+	if clientRequestMapping.concurrency != nil {
+		client = &concurrencyLimitedClient{name: requestConfig.name, client: client,
+			limiter: clientRequestMapping.concurrency}
+	}
+	if clientRequestMapping.bulkhead != nil {
+		client = &bulkheadClient{name: requestConfig.name, client: client, pool: clientRequestMapping.bulkhead}
+	}
+	if clientRequestMapping.rateLimiter != nil {
+		client = &rateLimitedClient{name: requestConfig.name, client: client, limiter: clientRequestMapping.rateLimiter}
+	}
+	return client
 }
 
 // This creates http client and setup transport based on RequestConfig settings.
+// The timeout is not set on the http client, it is applied to every attempt using the request context.
 // Following are default transport settings:
 // ForceAttemptHTTP2 : true
 // MaxIdleConnsPerHost : runtime.GOMAXPROCS(0) + 1
@@ -231,7 +461,6 @@ func getClient(requestConfig *RequestConfig) heimdall.Doer {
 	}
 	client := &http.Client{
 		Jar:           cookieJar,
-		Timeout:       requestConfig.timeout,
 		Transport:     requestConfig.transport,
 		CheckRedirect: requestConfig.checkRedirect,
 	}
@@ -272,21 +501,3 @@ func transport(c *http.Client) (*http.Transport, error) {
 	}
 	return nil, errors.New("current transport is not an *http.Transport instance")
 }
-
-// This constructs the retry function (ConstantBackoff, ExponentialBackoff or NoRetrier) based on
-// BackoffPolicy settings provided in RequestConfig
-// NoRetry is used if no BackoffPolicy setting are provided
-func getRetrier(requestConfig *RequestConfig) heimdall.Retriable {
-	if requestConfig.backoffPolicy != nil && requestConfig.backoffPolicy.constantBackoff != nil {
-		return heimdall.NewRetrier(heimdall.NewConstantBackoff(requestConfig.backoffPolicy.constantBackoff.interval,
-			requestConfig.backoffPolicy.constantBackoff.maximumJitterInterval))
-	} else if requestConfig.backoffPolicy != nil && requestConfig.backoffPolicy.exponentialBackoff != nil {
-		return heimdall.NewRetrier(heimdall.NewExponentialBackoff(
-			requestConfig.backoffPolicy.exponentialBackoff.initialTimeout,
-			requestConfig.backoffPolicy.exponentialBackoff.maxTimeout,
-			requestConfig.backoffPolicy.exponentialBackoff.exponentFactor,
-			requestConfig.backoffPolicy.exponentialBackoff.maximumJitterInterval))
-	}
-
-	return heimdall.NewNoRetrier()
-}
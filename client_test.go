@@ -1,7 +1,10 @@
 package httpclient
 
 import (
+	"errors"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/go-playground/assert"
@@ -50,9 +53,95 @@ func TestDemo(t *testing.T) {
 
 	require.NoError(t, err, "should not have failed to make a GET request")
 
-	assert.Equal(t, req.headerParams["flag"], "true")
-	assert.Equal(t, req.headerParams["source"], "internal")
-	assert.Equal(t, req.headerParams["limit"], "10")
+	assert.Equal(t, res.Request.Header.Get("flag"), "true")
+	assert.Equal(t, res.Request.Header.Get("source"), "internal")
+	assert.Equal(t, res.Request.Header.Get("limit"), "10")
 
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 }
+
+func TestUnknownRequest(t *testing.T) {
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil), NewRequestConfig("another", nil))
+
+	assert.Equal(t, client.Has("test"), true)
+	assert.Equal(t, client.Has("tset"), false)
+	assert.Equal(t, client.Names(), []string{"another", "test"})
+
+	res, err := client.Request(NewRequest("tset"))
+	require.Error(t, err)
+	require.Nil(t, res)
+	require.True(t, errors.Is(err, ErrUnknownRequest))
+
+	var unknownErr *UnknownRequestError
+	require.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, unknownErr.Name, "tset")
+	assert.Equal(t, unknownErr.Configured, []string{"another", "test"})
+}
+
+func TestMultiValuedParams(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetHeaderParams(map[string]interface{}{"source": "internal", "accept": "text/plain"}))
+
+	_, err := client.Request(NewRequest("test").
+		AddQueryParam("tag", "a").AddQueryParam("tag", "b").
+		SetQueryValues(url.Values{"id": {"1", "2"}}).
+		AddHeader("Accept", "application/json").AddHeader("Accept", "application/xml").
+		SetHeaders(http.Header{"X-Trace": {"x", "y"}}))
+	require.NoError(t, err)
+
+	assert.Equal(t, received.URL.Query()["tag"], []string{"a", "b"})
+	assert.Equal(t, received.URL.Query()["id"], []string{"1", "2"})
+	assert.Equal(t, received.Header["Accept"], []string{"application/json", "application/xml"})
+	assert.Equal(t, received.Header["X-Trace"], []string{"x", "y"})
+	assert.Equal(t, received.Header.Get("source"), "internal")
+}
+
+func TestHeaderMerge(t *testing.T) {
+	received := make(chan http.Header, 20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	requestConfig := NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetHeaderParams(map[string]interface{}{"source": "internal", "flag": "true"})
+	client := ConfigureHTTPClient(requestConfig)
+
+	res, err := client.Request(NewRequest("test").SetHeaderParam("flag", "false").SetHeaderParam("limit", "10"))
+	require.NoError(t, err)
+	header := <-received
+	assert.Equal(t, header.Get("source"), "internal")
+	assert.Equal(t, header.Get("flag"), "false")
+	assert.Equal(t, header.Get("limit"), "10")
+	assert.NotEqual(t, header.Get(requestIDHeader), "")
+	require.NoError(t, res.Body.Close())
+
+	_, err = client.Request(NewRequest("test").RemoveHeader("source").SetHeaderParam(requestIDHeader, "id"))
+	require.NoError(t, err)
+	header = <-received
+	assert.Equal(t, header.Get("source"), "")
+	assert.Equal(t, header.Get("flag"), "true")
+	assert.Equal(t, header.Get(requestIDHeader), "id")
+
+	// the static headers are shared by concurrent requests, and must never be modified
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			_, _ = client.Request(NewRequest("test"))
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+		<-received
+	}
+	assert.Equal(t, requestConfig.headers, map[string]string{"source": "internal", "flag": "true"})
+}
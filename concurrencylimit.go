@@ -0,0 +1,260 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gojek/heimdall"
+)
+
+const (
+	defaultInitialConcurrencyLimit = 20
+	defaultMinConcurrencyLimit     = 1
+	defaultMaxConcurrencyLimit     = 200
+	defaultConcurrencyBackoffRatio = 0.9
+	concurrencyLatencySamples      = 100
+	concurrencyLatencyTolerance    = 2
+)
+
+// ErrConcurrencyLimited is matched using errors.Is by the error returned for a request which is over the
+// concurrency limit
+var ErrConcurrencyLimited = errors.New("concurrency limited")
+
+// ConcurrencyLimitError is the error returned for a request which is over the concurrency limit
+type ConcurrencyLimitError struct {
+	Name  string
+	Limit int
+}
+
+// Error is used to get the error message
+func (e *ConcurrencyLimitError) Error() string {
+	return fmt.Sprintf("request %s is rejected, %d requests are already in flight", e.Name, e.Limit)
+}
+
+// Is is used to match the error with ErrConcurrencyLimited
+func (e *ConcurrencyLimitError) Is(target error) bool {
+	return target == ErrConcurrencyLimited
+}
+
+// ConcurrencyLimit is the type for limiting the requests in flight with the same name, to a limit which is increased
+// by one while the requests succeed quickly, and decreased by the backoff ratio when they fail or are slow
+type ConcurrencyLimit struct {
+	initialLimit     int
+	minLimit         int
+	maxLimit         int
+	latencyThreshold time.Duration
+	backoffRatio     float64
+}
+
+// NewConcurrencyLimit is used to create a new concurrency limit
+func NewConcurrencyLimit(configMap map[string]interface{}) *ConcurrencyLimit {
+	concurrencyLimit := &ConcurrencyLimit{}
+	concurrencyLimit.initialLimit, _ = getConfigOptionInt(configMap, "initiallimit")
+	concurrencyLimit.minLimit, _ = getConfigOptionInt(configMap, "minlimit")
+	concurrencyLimit.maxLimit, _ = getConfigOptionInt(configMap, "maxlimit")
+	latencyThreshold, err := getConfigOptionInt(configMap, "latencythresholdinmillis")
+	if err == nil {
+		concurrencyLimit.latencyThreshold = time.Duration(latencyThreshold) * time.Millisecond
+	}
+	concurrencyLimit.backoffRatio, _ = getConfigOptionFloat(configMap, "backoffratio")
+	return concurrencyLimit
+}
+
+// SetInitialLimit is used to set the limit the requests start with
+// if not done, then 20 is used
+func (cl *ConcurrencyLimit) SetInitialLimit(initialLimit int) *ConcurrencyLimit {
+	cl.initialLimit = initialLimit
+	return cl
+}
+
+// SetMinLimit is used to set the limit below which it is not decreased
+// if not done, then 1 is used
+func (cl *ConcurrencyLimit) SetMinLimit(minLimit int) *ConcurrencyLimit {
+	cl.minLimit = minLimit
+	return cl
+}
+
+// SetMaxLimit is used to set the limit above which it is not increased
+// if not done, then 200 is used
+func (cl *ConcurrencyLimit) SetMaxLimit(maxLimit int) *ConcurrencyLimit {
+	cl.maxLimit = maxLimit
+	return cl
+}
+
+// SetLatencyThreshold is used to set the latency above which a request is slow
+// if not done, then twice the lowest of the recent latencies is used
+func (cl *ConcurrencyLimit) SetLatencyThreshold(latencyThreshold time.Duration) *ConcurrencyLimit {
+	cl.latencyThreshold = latencyThreshold
+	return cl
+}
+
+// SetBackoffRatio is used to set the ratio, between 0 and 1, the limit is multiplied with when a request fails or is slow
+// if not done, then 0.9 is used
+func (cl *ConcurrencyLimit) SetBackoffRatio(backoffRatio float64) *ConcurrencyLimit {
+	cl.backoffRatio = backoffRatio
+	return cl
+}
+
+// concurrencyLimiter keeps the requests in flight and the current limit of the concurrency limit
+type concurrencyLimiter struct {
+	minLimit         float64
+	maxLimit         float64
+	latencyThreshold time.Duration
+	backoffRatio     float64
+
+	mu        sync.Mutex
+	limit     float64
+	inFlight  int
+	latencies [concurrencyLatencySamples]time.Duration
+	count     int
+}
+
+// This creates the limiter for the concurrency limit, which is nil if there is no concurrency limit.
+func newConcurrencyLimiter(concurrencyLimit *ConcurrencyLimit) *concurrencyLimiter {
+	if concurrencyLimit == nil {
+		return nil
+	}
+	cl := &concurrencyLimiter{
+		minLimit:         float64(concurrencyLimit.minLimit),
+		maxLimit:         float64(concurrencyLimit.maxLimit),
+		latencyThreshold: concurrencyLimit.latencyThreshold,
+		backoffRatio:     concurrencyLimit.backoffRatio,
+		limit:            float64(concurrencyLimit.initialLimit),
+	}
+	if cl.minLimit <= 0 {
+		cl.minLimit = defaultMinConcurrencyLimit
+	}
+	if cl.maxLimit <= 0 {
+		cl.maxLimit = math.Max(defaultMaxConcurrencyLimit, cl.minLimit)
+	}
+	if cl.backoffRatio <= 0 || cl.backoffRatio >= 1 {
+		cl.backoffRatio = defaultConcurrencyBackoffRatio
+	}
+	if cl.limit <= 0 {
+		cl.limit = defaultInitialConcurrencyLimit
+	}
+	cl.limit = math.Min(math.Max(cl.limit, cl.minLimit), cl.maxLimit)
+	return cl
+}
+
+// This gets the current limit, which is 0 if there is no concurrency limit.
+func (cl *concurrencyLimiter) getLimit() int {
+	if cl == nil {
+		return 0
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return int(cl.limit)
+}
+
+// This records a request in flight if it is within the limit, and returns whether it is within the limit.
+func (cl *concurrencyLimiter) acquire() (int, bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	limit := int(cl.limit)
+	if cl.inFlight >= limit {
+		return limit, false
+	}
+	cl.inFlight++
+	return limit, true
+}
+
+// This records the end of a request in flight, increasing the limit by one for every limit requests which succeeded
+// within the latency threshold, and decreasing it by the backoff ratio for the request which failed or was slow.
+func (cl *concurrencyLimiter) release(latency time.Duration, outcome concurrencyOutcome) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.inFlight--
+	switch outcome {
+	case concurrencyIgnored:
+		return
+	case concurrencyDropped:
+		cl.limit = math.Max(cl.limit*cl.backoffRatio, cl.minLimit)
+		return
+	}
+
+	threshold := cl.latencyThreshold
+	if threshold <= 0 {
+		threshold = cl.minLatency() * concurrencyLatencyTolerance
+	}
+	cl.latencies[cl.count%concurrencyLatencySamples] = latency
+	cl.count++
+	if threshold > 0 && latency > threshold {
+		cl.limit = math.Max(cl.limit*cl.backoffRatio, cl.minLimit)
+		return
+	}
+	cl.limit = math.Min(cl.limit+1/cl.limit, cl.maxLimit)
+}
+
+// This gets the lowest of the recent latencies, it returns 0 if there are none.
+func (cl *concurrencyLimiter) minLatency() time.Duration {
+	n := cl.count
+	if n > concurrencyLatencySamples {
+		n = concurrencyLatencySamples
+	}
+	var latency time.Duration
+	for i := 0; i < n; i++ {
+		if i == 0 || cl.latencies[i] < latency {
+			latency = cl.latencies[i]
+		}
+	}
+	return latency
+}
+
+// concurrencyOutcome is how the outcome of a request changes the concurrency limit
+type concurrencyOutcome int
+
+const (
+	concurrencySucceeded concurrencyOutcome = iota
+	concurrencyDropped
+	concurrencyIgnored
+)
+
+// This classifies the outcome of a request. The requests which were cancelled, or were not made at all by the circuit
+// breaker, do not say anything about the load on the endpoint.
+func getConcurrencyOutcome(response *http.Response, err error) concurrencyOutcome {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrMaxConcurrency):
+		return concurrencyIgnored
+	case err != nil, response.StatusCode >= http.StatusInternalServerError,
+		response.StatusCode == http.StatusTooManyRequests:
+		return concurrencyDropped
+	}
+	return concurrencySucceeded
+}
+
+// concurrencyLimitedClient makes the requests within the concurrency limit
+type concurrencyLimitedClient struct {
+	name    string
+	client  heimdall.Doer
+	limiter *concurrencyLimiter
+}
+
+// Do is used to make the request if it is within the concurrency limit, the request being in flight until its
+// response body is closed. The latency is still the time taken to get the response.
+func (clc *concurrencyLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	limit, ok := clc.limiter.acquire()
+	if !ok {
+		closeRequestBody(req)
+		return nil, &ConcurrencyLimitError{Name: clc.name, Limit: limit}
+	}
+	start := time.Now()
+	response, err := clc.client.Do(req)
+	latency, outcome := time.Since(start), getConcurrencyOutcome(response, err)
+	release := func() {
+		clc.limiter.release(latency, outcome)
+	}
+	if response == nil {
+		release()
+		return response, err
+	}
+	response.Body = &releaseOnCloseBody{ReadCloser: response.Body, release: release}
+	return response, err
+}
@@ -0,0 +1,107 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	var requests int32
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var metrics []Metric
+	client := ConfigureHTTPClient(NewRequestConfig("test", map[string]interface{}{
+		"method":     http.MethodGet,
+		"url":        server.URL,
+		"retrycount": 2,
+		"concurrencylimit": map[string]interface{}{
+			"initiallimit": 2,
+			"maxlimit":     2,
+		},
+	})).WithMetrics(func(ctx context.Context, name string, m Metric) {
+		mu.Lock()
+		defer mu.Unlock()
+		metrics = append(metrics, m)
+	}).WithFailedRequestMetrics()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.Request(NewRequest("test"))
+			require.NoError(t, err)
+			assert.Equal(t, res.StatusCode, http.StatusOK)
+			require.NoError(t, res.Body.Close())
+		}()
+	}
+	<-started
+	<-started
+
+	// the requests over the limit are rejected right away, and are not retried
+	_, err := client.Request(NewRequest("test"))
+	var limitErr *ConcurrencyLimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.True(t, errors.Is(err, ErrConcurrencyLimited))
+	assert.Equal(t, limitErr.Limit, 2)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+
+	// the request is in flight until its response body is closed
+	limiter := client.httpClients["test"].concurrency
+	res, err := client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, limiter.inFlight, 1)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, limiter.inFlight, 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, metrics, 4)
+	for _, m := range metrics {
+		assert.Equal(t, m.ConcurrencyLimit, 2)
+	}
+
+	limiter = newConcurrencyLimiter(NewConcurrencyLimit(nil).SetInitialLimit(10).SetMinLimit(5).
+		SetLatencyThreshold(100 * time.Millisecond))
+	_, ok := limiter.acquire()
+	require.True(t, ok)
+	limiter.release(10*time.Millisecond, concurrencySucceeded)
+	assert.Equal(t, limiter.getLimit(), 10)
+	limiter.release(200*time.Millisecond, concurrencySucceeded)
+	assert.Equal(t, limiter.getLimit(), 9)
+	limiter.release(0, concurrencyIgnored)
+	assert.Equal(t, limiter.getLimit(), 9)
+	for i := 0; i < 10; i++ {
+		limiter.release(0, concurrencyDropped)
+	}
+	assert.Equal(t, limiter.getLimit(), 5)
+
+	// without the threshold, the requests slower than twice the lowest recent latency are slow
+	limiter = newConcurrencyLimiter(NewConcurrencyLimit(nil))
+	assert.Equal(t, limiter.getLimit(), 20)
+	limiter.release(10*time.Millisecond, concurrencySucceeded)
+	limiter.release(15*time.Millisecond, concurrencySucceeded)
+	assert.Equal(t, limiter.getLimit(), 20)
+	limiter.release(30*time.Millisecond, concurrencySucceeded)
+	assert.Equal(t, limiter.getLimit(), 18)
+}
@@ -3,9 +3,23 @@ package httpclient
 import "time"
 
 var (
-	defaultKeepAlive             = time.Second * 30
-	defaultIdleConnectionTimeout = time.Second * 90
-	defaultSleepWindowInMillis   = 5000
-	requestIDHeader              = "X-requestId"
-	idParam                      = "id"
+	defaultKeepAlive              = time.Second * 30
+	defaultIdleConnectionTimeout  = time.Second * 90
+	defaultSleepWindowInMillis    = 5000
+	defaultRollingWindowInMillis  = 10000
+	defaultErrorPercentThreshold  = 50
+	defaultRequestVolumeThreshold = 20
+	defaultConsecutiveFailures    = 5
+	defaultHalfOpenProbes         = 1
+	defaultHystrixTimeout         = time.Second
+	defaultMaxConcurrentRequests  = 10
+	requestIDHeader               = "X-requestId"
+	idParam                       = "id"
+	contentTypeHeader             = "Content-Type"
+	retryAfterHeader              = "Retry-After"
+	defaultMaxRetryAfter          = time.Second * 30
+	idempotencyKeyHeader          = "Idempotency-Key"
+	jsonContentType               = "application/json"
+	maxStatusErrorBodySize        = 4096
+	defaultMaxBodyBufferSize      = int64(4 << 20)
 )
@@ -0,0 +1,118 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	url := server.URL
+
+	fallback := func(ctx context.Context, request *Request, err error) (*http.Response, error) {
+		if errors.Is(err, ErrCircuitOpen) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{contentTypeHeader: []string{jsonContentType}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"cached":true}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("fallback: %w", err)
+	}
+	var metrics []Metric
+	client := ConfigureHTTPClient(
+		NewRequestConfig("hystrix", nil).SetMethod(http.MethodGet).SetURL(url).
+			SetHystrixConfig(NewHystrixConfig(nil)).SetFallback(fallback),
+		NewRequestConfig("plain", nil).SetMethod(http.MethodGet).SetURL(url).SetFallback(fallback)).
+		WithMetrics(func(ctx context.Context, name string, m Metric) {
+			if m.Kind == MetricKindRequest {
+				metrics = append(metrics, m)
+			}
+		}).WithFailedRequestMetrics()
+
+	res, err := client.Request(NewRequest("hystrix"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	require.NoError(t, res.Body.Close())
+
+	// the response of the fallback is served when the circuit is open
+	require.NoError(t, client.ForceCircuitOpen("hystrix"))
+	var out map[string]bool
+	res, err = client.RequestJSON(NewRequest("hystrix"), &out)
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, out["cached"], true)
+	require.NotNil(t, res.Request)
+
+	server.Close()
+	_, err = client.Request(NewRequest("plain").DisableRetries())
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "fallback: "))
+
+	require.Len(t, metrics, 3)
+	assert.Equal(t, metrics[0].Fallback, false)
+	assert.Equal(t, metrics[1].Fallback, true)
+	assert.Equal(t, metrics[1].Status, http.StatusOK)
+	// the fallback which only returns an error does not serve the request
+	assert.Equal(t, metrics[2].Fallback, false)
+	assert.Equal(t, metrics[2].Status, 0)
+}
+
+func TestFallbackOnStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("unavailable"))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	var errs []error
+	fallback := func(ctx context.Context, request *Request, err error) (*http.Response, error) {
+		errs = append(errs, err)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetRetryCount(1).SetFallback(fallback).SetErrorOnStatus(func(status int) bool {
+		return status == http.StatusNotFound
+	}))
+
+	// the fallback is called for the 5xx response left after the retries
+	res, err := client.Request(NewRequest("test").SetPath("/unavailable"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	require.Len(t, errs, 1)
+	var statusErr *StatusError
+	require.True(t, errors.As(errs[0], &statusErr))
+	assert.Equal(t, statusErr.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, string(statusErr.Body), "unavailable")
+
+	// and for the status code which is an error as per the config
+	res, err = client.Request(NewRequest("test").SetPath("/missing"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	require.Len(t, errs, 2)
+	require.True(t, errors.As(errs[1], &statusErr))
+	assert.Equal(t, statusErr.StatusCode, http.StatusNotFound)
+
+	res, err = client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	require.Len(t, errs, 2)
+}
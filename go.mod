@@ -3,18 +3,12 @@ module github.com/sinhashubham95/go-http-client
 go 1.16
 
 require (
-	github.com/afex/hystrix-go v0.0.0-20180502004556-fa1af6a1f4f5 // indirect
-	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/go-playground/assert v1.2.1
 	github.com/gojek/heimdall v5.0.2+incompatible
-	github.com/gojektech/heimdall v5.0.2+incompatible // indirect
-	github.com/gojektech/valkyrie v0.0.0-20190210220504-8f62c1e7ba45 // indirect
 	github.com/google/uuid v1.6.0
-	github.com/pkg/errors v0.9.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/smartystreets/goconvey v1.8.1 // indirect
 	github.com/spf13/cast v1.6.0
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/net v0.22.0
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
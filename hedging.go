@@ -0,0 +1,177 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	latencySamples    = 128
+	minLatencySamples = 20
+)
+
+// hedgeResult is the outcome of one of the requests sent for an attempt
+type hedgeResult struct {
+	response *http.Response
+	err      error
+	hedge    int
+	cancel   context.CancelFunc
+}
+
+// This makes the attempt using the client of the mapping, hedging it if configured. Only the GET and HEAD
+// requests without a body are hedged, as the requests sent for the same attempt must not affect each other.
+// Without a positive delay the requests would all be sent at once, so they are not hedged either.
+func (c *Client) do(client ClientRequestMapping, req *http.Request, body *requestBody,
+	metric *Metric) (*http.Response, error) {
+	if client.requestConfig.maxHedges <= 0 || client.requestConfig.hedgeDelay <= 0 || body.get != nil ||
+		(req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return client.doer.Do(req)
+	}
+	return c.doHedged(client, req, metric)
+}
+
+// This sends the request, and another one every hedge delay while none has succeeded, up to the max hedges.
+// The first successful response is returned, and the other requests are cancelled and their responses drained.
+// A failure is returned once no other request is in flight, leaving it to be retried as per the RetryPolicy.
+func (c *Client) doHedged(client ClientRequestMapping, req *http.Request, metric *Metric) (*http.Response, error) {
+	maxHedges := client.requestConfig.maxHedges
+	results := make(chan hedgeResult, maxHedges+1)
+	cancels := make([]context.CancelFunc, 0, maxHedges+1)
+	send := func() {
+		hedge := len(cancels)
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		go func() {
+			start := time.Now()
+			response, err := client.doer.Do(req.WithContext(ctx))
+			if err == nil {
+				client.latencies.record(time.Since(start))
+			}
+			results <- hedgeResult{response: response, err: err, hedge: hedge, cancel: cancel}
+		}()
+	}
+
+	delay := client.getHedgeDelay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	send()
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			send()
+			pending++
+			metric.Hedges++
+			if len(cancels) <= maxHedges {
+				timer.Reset(delay)
+			}
+		case result := <-results:
+			pending--
+			failed := client.requestConfig.retryPolicy.shouldRetry(result.response, result.err)
+			if failed && pending > 0 {
+				// the requests still in flight may succeed
+				discardHedge(result)
+				continue
+			}
+
+			for hedge, cancel := range cancels {
+				if hedge != result.hedge {
+					cancel()
+				}
+			}
+			go drainHedges(results, pending)
+			metric.HedgeWon = result.hedge > 0
+			if result.response == nil {
+				result.cancel()
+				return nil, result.err
+			}
+			result.response.Body = &cancelOnCloseBody{ReadCloser: result.response.Body, cancel: result.cancel}
+			return result.response, result.err
+		}
+	}
+}
+
+// This gets the delay before sending the next hedge, which is the configured percentile of the recent latencies
+// once there are enough of them, but never less than the configured delay.
+func (client ClientRequestMapping) getHedgeDelay() time.Duration {
+	delay := client.requestConfig.hedgeDelay
+	if latency, ok := client.latencies.percentile(client.requestConfig.hedgePercentile); ok && latency > delay {
+		return latency
+	}
+	return delay
+}
+
+func discardHedge(result hedgeResult) {
+	if result.response != nil {
+		_, _ = io.Copy(ioutil.Discard, result.response.Body)
+		_ = result.response.Body.Close()
+	}
+	result.cancel()
+}
+
+// This discards the responses of the requests still in flight, once they are done.
+func drainHedges(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		discardHedge(<-results)
+	}
+}
+
+// latencyTracker keeps the recent latencies of the requests to an endpoint
+type latencyTracker struct {
+	mu        sync.Mutex
+	latencies [latencySamples]time.Duration
+	count     int
+}
+
+// This creates the tracker for the hedge delay, which is nil if the delay is not a percentile of the latencies.
+func newLatencyTracker(requestConfig *RequestConfig) *latencyTracker {
+	if requestConfig.maxHedges <= 0 || requestConfig.hedgePercentile <= 0 {
+		return nil
+	}
+	return &latencyTracker{}
+}
+
+func (t *latencyTracker) record(latency time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.latencies[t.count%latencySamples] = latency
+	t.count++
+}
+
+// This gets the percentile of the recent latencies, it returns false if there are not enough of them.
+func (t *latencyTracker) percentile(percentile float64) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.mu.Lock()
+	n := t.count
+	if n > latencySamples {
+		n = latencySamples
+	}
+	latencies := make([]time.Duration, n)
+	copy(latencies, t.latencies[:n])
+	t.mu.Unlock()
+
+	if n < minLatencySamples {
+		return 0, false
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	index := int(math.Ceil(percentile/100*float64(n))) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= n {
+		index = n - 1
+	}
+	return latencies[index], true
+}
@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedging(t *testing.T) {
+	var requests int32
+	cancelled := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// the first request is slow, and is cancelled once the hedge wins
+			select {
+			case <-r.Context().Done():
+				cancelled <- struct{}{}
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var metrics []Metric
+	client := ConfigureHTTPClient(NewRequestConfig("test", map[string]interface{}{
+		"method":     http.MethodGet,
+		"url":        server.URL,
+		"retrycount": 0,
+		"hedging": map[string]interface{}{
+			"delayinmillis": 20,
+			"maxhedges":     2,
+		},
+	})).WithMetrics(func(ctx context.Context, name string, m Metric) {
+		metrics = append(metrics, m)
+	})
+
+	start := time.Now()
+	res, err := client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	require.NoError(t, res.Body.Close())
+	require.True(t, time.Since(start) < 500*time.Millisecond)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow request is not cancelled")
+	}
+	require.Len(t, metrics, 1)
+	assert.Equal(t, metrics[0].Hedges, 1)
+	assert.Equal(t, metrics[0].HedgeWon, true)
+
+	// the requests which are not GET are not hedged
+	atomic.StoreInt32(&requests, 1)
+	res, err = client.Request(NewRequest("test").SetMethod(http.MethodPost))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+	assert.Equal(t, metrics[1].Hedges, 0)
+
+	tracker := &latencyTracker{}
+	_, ok := tracker.percentile(95)
+	assert.Equal(t, ok, false)
+	for i := 1; i <= 100; i++ {
+		tracker.record(time.Duration(i) * time.Millisecond)
+	}
+	latency, ok := tracker.percentile(95)
+	require.True(t, ok)
+	assert.Equal(t, latency, 95*time.Millisecond)
+
+	// the configured delay is used until there are enough latencies, and as the least delay after that
+	mapping := ClientRequestMapping{requestConfig: NewRequestConfig("test", nil).SetHedging(50*time.Millisecond, 1).
+		SetHedgingPercentile(95), latencies: &latencyTracker{}}
+	assert.Equal(t, mapping.getHedgeDelay(), 50*time.Millisecond)
+	mapping.latencies = tracker
+	assert.Equal(t, mapping.getHedgeDelay(), 95*time.Millisecond)
+	mapping.requestConfig.SetHedging(200*time.Millisecond, 1)
+	assert.Equal(t, mapping.getHedgeDelay(), 200*time.Millisecond)
+
+	// without a delay the requests are not hedged, as they would all be sent at once
+	_, err = NewRequestConfigStrict("test", map[string]interface{}{
+		"method":  http.MethodGet,
+		"url":     server.URL,
+		"hedging": map[string]interface{}{"maxhedges": 2, "percentile": 95},
+	})
+	require.EqualError(t, err, "invalid request config test: hedging.delayinmillis: must be positive when maxhedges is set")
+	atomic.StoreInt32(&requests, 1)
+	client.Upsert(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).SetHedging(0, 2).
+		SetHedgingPercentile(95))
+	res, err = client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+	assert.Equal(t, metrics[2].Hedges, 0)
+}
@@ -1,6 +1,9 @@
 package httpclient
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // HystrixConfig is the configuration for hystrix
 type HystrixConfig struct {
@@ -9,6 +12,10 @@ type HystrixConfig struct {
 	errorPercentThreshold  int
 	sleepWindowInMillis    int
 	requestVolumeThreshold int
+	rollingWindowInMillis  int
+	mode                   CircuitBreakerMode
+	consecutiveFailures    int
+	halfOpenProbes         int
 	fallback               func(error) error
 }
 
@@ -28,16 +35,39 @@ func NewHystrixConfig(configMap map[string]interface{}) *HystrixConfig {
 	hystrixConfig.maxConcurrentRequests, _ = getConfigOptionInt(configMap, "maxconcurrentrequests")
 	hystrixConfig.errorPercentThreshold, _ = getConfigOptionInt(configMap, "errorpercentthreshold")
 	hystrixConfig.requestVolumeThreshold, _ = getConfigOptionInt(configMap, "requestvolumethreshold")
+	hystrixConfig.rollingWindowInMillis, _ = getConfigOptionInt(configMap, "rollingwindowinmillis")
+	mode, err := getConfigOptionString(configMap, "mode")
+	if err == nil {
+		hystrixConfig.mode = CircuitBreakerMode(strings.ToLower(mode))
+	}
+	hystrixConfig.consecutiveFailures, _ = getConfigOptionInt(configMap, "consecutivefailures")
+	hystrixConfig.halfOpenProbes, _ = getConfigOptionInt(configMap, "halfopenprobes")
 	return hystrixConfig
 }
 
+// This checks whether the circuit breakers created for both the configurations would be the same, the fallback
+// and the timeout not being a part of the circuit breaker.
+func (hc *HystrixConfig) sameCircuit(other *HystrixConfig) bool {
+	return hc != nil && other != nil &&
+		hc.maxConcurrentRequests == other.maxConcurrentRequests &&
+		hc.errorPercentThreshold == other.errorPercentThreshold &&
+		hc.sleepWindowInMillis == other.sleepWindowInMillis &&
+		hc.requestVolumeThreshold == other.requestVolumeThreshold &&
+		hc.rollingWindowInMillis == other.rollingWindowInMillis &&
+		hc.mode == other.mode &&
+		hc.consecutiveFailures == other.consecutiveFailures &&
+		hc.halfOpenProbes == other.halfOpenProbes
+}
+
 // SetHystrixTimeout is used to set the hystrix timeout
+// if not done, then 1 second is used
 func (hc *HystrixConfig) SetHystrixTimeout(hystrixTimeout time.Duration) *HystrixConfig {
 	hc.hystrixTimeout = hystrixTimeout
 	return hc
 }
 
 // SetMaxConcurrentRequests is used to set the max concurrent requests in hystrix
+// if not done, then 10 is used
 func (hc *HystrixConfig) SetMaxConcurrentRequests(maxConcurrentRequests int) *HystrixConfig {
 	hc.maxConcurrentRequests = maxConcurrentRequests
 	return hc
@@ -61,6 +91,33 @@ func (hc *HystrixConfig) SetRequestVolumeThreshold(requestVolumeThreshold int) *
 	return hc
 }
 
+// SetRollingWindowInMillis is used to set the window over which the error percentage is computed
+// if not done, then 10 seconds is used
+func (hc *HystrixConfig) SetRollingWindowInMillis(rollingWindowInMillis int) *HystrixConfig {
+	hc.rollingWindowInMillis = rollingWindowInMillis
+	return hc
+}
+
+// SetMode is used to set whether the circuit opens on the error percentage or the consecutive failures
+// if not done, then the error percentage is used
+func (hc *HystrixConfig) SetMode(mode CircuitBreakerMode) *HystrixConfig {
+	hc.mode = mode
+	return hc
+}
+
+// SetConsecutiveFailures is used to set the consecutive failures opening the circuit in the consecutive mode
+func (hc *HystrixConfig) SetConsecutiveFailures(consecutiveFailures int) *HystrixConfig {
+	hc.consecutiveFailures = consecutiveFailures
+	return hc
+}
+
+// SetHalfOpenProbes is used to set the requests made once the sleep window is over, all of which must succeed
+// to close the circuit again
+func (hc *HystrixConfig) SetHalfOpenProbes(halfOpenProbes int) *HystrixConfig {
+	hc.halfOpenProbes = halfOpenProbes
+	return hc
+}
+
 // SetFallback is used to set the fallback
 func (hc *HystrixConfig) SetFallback(fallbackFn func(error) error) *HystrixConfig {
 	hc.fallback = fallbackFn
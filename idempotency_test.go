@@ -0,0 +1,87 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentRetries(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(idempotencyKeyHeader))
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	reset := func() {
+		mu.Lock()
+		keys = nil
+		mu.Unlock()
+	}
+
+	requestConfig, err := NewRequestConfigStrict("keyed", map[string]interface{}{
+		"method":     http.MethodPost,
+		"url":        server.URL,
+		"retrycount": 2,
+		"retrypolicy": map[string]interface{}{
+			"idempotencykey": true,
+		},
+	})
+	require.NoError(t, err)
+	client := ConfigureHTTPClient(requestConfig,
+		NewRequestConfig("post", nil).SetMethod(http.MethodPost).SetURL(server.URL).SetRetryCount(2),
+		NewRequestConfig("get", nil).SetMethod(http.MethodGet).SetURL(server.URL).SetRetryCount(2),
+		NewRequestConfig("optin", nil).SetMethod(http.MethodPatch).SetURL(server.URL).SetRetryCount(2).
+			SetRetryPolicy(NewRetryPolicy(nil).SetRetryNonIdempotent(true)),
+		NewRequestConfig("predicate", nil).SetMethod(http.MethodPost).SetURL(server.URL).SetRetryCount(2).
+			SetRetryPolicy(NewRetryPolicy(nil).SetPredicate(func(*http.Response, error) bool { return true })))
+
+	// the non idempotent methods are not retried by default
+	_, err = client.Request(NewRequest("post"))
+	require.NoError(t, err)
+	assert.Equal(t, keys, []string{""})
+
+	// nor when only a predicate is set
+	reset()
+	_, err = client.Request(NewRequest("predicate"))
+	require.NoError(t, err)
+	assert.Equal(t, keys, []string{""})
+
+	reset()
+	_, err = client.Request(NewRequest("get"))
+	require.NoError(t, err)
+	assert.Equal(t, keys, []string{"", "", ""})
+
+	reset()
+	_, err = client.Request(NewRequest("optin"))
+	require.NoError(t, err)
+	assert.Equal(t, keys, []string{"", "", ""})
+
+	// the key is the same for all the attempts of a request, and different for another request
+	reset()
+	_, err = client.Request(NewRequest("keyed"))
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	require.NotEmpty(t, keys[0])
+	assert.Equal(t, keys, []string{keys[0], keys[0], keys[0]})
+	first := keys[0]
+
+	reset()
+	_, err = client.Request(NewRequest("keyed"))
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	require.NotEqual(t, keys[0], first)
+
+	// the key set in the request is kept
+	reset()
+	_, err = client.Request(NewRequest("keyed").SetHeaderParam(idempotencyKeyHeader, "abc"))
+	require.NoError(t, err)
+	assert.Equal(t, keys, []string{"abc", "abc", "abc"})
+}
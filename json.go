@@ -0,0 +1,45 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// RequestJSON executes the Request same as Request, and decodes the json body of a successful response into out.
+// The response body is always closed, and for a status code other than 2xx a StatusError is returned.
+// If out is nil, then the response body is discarded.
+func (c *Client) RequestJSON(request *Request, out interface{}) (*http.Response, error) {
+	response, err := c.Request(request)
+	if err != nil {
+		return response, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return response, newStatusError(request.name, response)
+	}
+
+	if out == nil || response.StatusCode == http.StatusNoContent {
+		return response, nil
+	}
+	err = json.NewDecoder(response.Body).Decode(out)
+	if err == io.EOF {
+		// an empty body leaves out untouched
+		err = nil
+	}
+	return response, err
+}
+
+// This gets the body to pass in the http request, encoding the json body if set.
+func getRequestBody(request *Request) (io.Reader, error) {
+	if request.jsonBody == nil {
+		return request.body, nil
+	}
+	data, err := json.Marshal(request.jsonBody)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
@@ -0,0 +1,53 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestRequestJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": "not found"}`))
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var u user
+		_ = json.NewDecoder(r.Body).Decode(&u)
+		u.ID = 42
+		_ = json.NewEncoder(w).Encode(u)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodPost).SetURL(server.URL))
+
+	var out user
+	res, err := client.RequestJSON(NewRequest("test").SetJSONBody(user{Name: "test"}), &out)
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, out, user{ID: 42, Name: "test"})
+
+	_, err = client.RequestJSON(NewRequest("test").SetURL(server.URL+"/missing"), &out)
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, statusErr.StatusCode, http.StatusNotFound)
+	assert.Equal(t, string(statusErr.Body), `{"error": "not found"}`)
+
+	_, err = client.RequestJSON(NewRequest("test").SetJSONBody(func() {}), &out)
+	require.Error(t, err)
+}
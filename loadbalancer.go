@@ -0,0 +1,385 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojek/heimdall"
+	"github.com/spf13/cast"
+)
+
+// LoadBalancingStrategy decides the endpoint every request is made to, when multiple urls are configured
+type LoadBalancingStrategy string
+
+// supported load balancing strategies
+const (
+	LoadBalancingRoundRobin        LoadBalancingStrategy = "roundrobin"
+	LoadBalancingWeighted          LoadBalancingStrategy = "weighted"
+	LoadBalancingLeastOutstanding  LoadBalancingStrategy = "leastoutstanding"
+	LoadBalancingPowerOfTwoChoices LoadBalancingStrategy = "p2c"
+)
+
+const (
+	defaultEjectionConsecutiveFailures = 5
+	defaultEjectionTime                = 30 * time.Second
+	defaultMaxEjectionPercent          = 50
+)
+
+// Endpoint is one of the urls the requests are balanced across, the weight being used by the weighted strategy
+type Endpoint struct {
+	URL    string
+	Weight int
+}
+
+// This converts the urls of the config map, which are either urls or maps with the url and weight.
+func toEndpointsE(val interface{}) ([]Endpoint, error) {
+	if urls, ok := val.([]string); ok {
+		endpoints := make([]Endpoint, 0, len(urls))
+		for _, u := range urls {
+			endpoints = append(endpoints, Endpoint{URL: u})
+		}
+		return endpoints, nil
+	}
+
+	items, err := cast.ToSliceE(val)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]Endpoint, 0, len(items))
+	for _, item := range items {
+		if u, ok := item.(string); ok {
+			endpoints = append(endpoints, Endpoint{URL: u})
+			continue
+		}
+		endpointMap, err := cast.ToStringMapE(item)
+		if err != nil {
+			return nil, err
+		}
+		u, err := getConfigOptionString(endpointMap, "url")
+		if err != nil {
+			return nil, err
+		}
+		endpoint := Endpoint{URL: u}
+		if _, ok := lookupConfigOption(endpointMap, "weight"); ok {
+			if endpoint.Weight, err = getConfigOptionInt(endpointMap, "weight"); err != nil {
+				return nil, err
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// LoadBalancer is the type for configuring how the requests are balanced across the endpoints,
+// and when an endpoint which keeps failing is ejected
+type LoadBalancer struct {
+	strategy            LoadBalancingStrategy
+	consecutiveFailures int
+	ejectionTime        time.Duration
+	maxEjectionPercent  int
+}
+
+// NewLoadBalancer is used to create a new load balancer
+func NewLoadBalancer(configMap map[string]interface{}) *LoadBalancer {
+	loadBalancer := &LoadBalancer{}
+	strategy, err := getConfigOptionString(configMap, "strategy")
+	if err == nil {
+		loadBalancer.strategy = LoadBalancingStrategy(strings.ToLower(strategy))
+	}
+	loadBalancer.consecutiveFailures, _ = getConfigOptionInt(configMap, "consecutivefailures")
+	ejectionTime, err := getConfigOptionInt(configMap, "ejectiontimeinmillis")
+	if err == nil {
+		loadBalancer.ejectionTime = time.Duration(ejectionTime) * time.Millisecond
+	}
+	loadBalancer.maxEjectionPercent, _ = getConfigOptionInt(configMap, "maxejectionpercent")
+	return loadBalancer
+}
+
+// SetStrategy is used to set the strategy picking the endpoint of every request
+// if not done, then round robin is used
+func (lb *LoadBalancer) SetStrategy(strategy LoadBalancingStrategy) *LoadBalancer {
+	lb.strategy = strategy
+	return lb
+}
+
+// SetConsecutiveFailures is used to set the failures in a row after which an endpoint is ejected
+// if not done, then 5 is used
+func (lb *LoadBalancer) SetConsecutiveFailures(consecutiveFailures int) *LoadBalancer {
+	lb.consecutiveFailures = consecutiveFailures
+	return lb
+}
+
+// SetEjectionTime is used to set the time for which an endpoint is ejected
+// if not done, then 30 seconds is used
+func (lb *LoadBalancer) SetEjectionTime(ejectionTime time.Duration) *LoadBalancer {
+	lb.ejectionTime = ejectionTime
+	return lb
+}
+
+// SetMaxEjectionPercent is used to set the percent of the endpoints which can be ejected at the same time
+// if not done, then 50 is used
+func (lb *LoadBalancer) SetMaxEjectionPercent(maxEjectionPercent int) *LoadBalancer {
+	lb.maxEjectionPercent = maxEjectionPercent
+	return lb
+}
+
+// endpointState keeps the requests in flight and the failures of an endpoint
+type endpointState struct {
+	url          string
+	base         *url.URL
+	weight       int
+	current      int
+	outstanding  int
+	failures     int
+	ejectedUntil time.Time
+}
+
+// endpointBalancer picks the endpoint of every request of a request config
+type endpointBalancer struct {
+	strategy            LoadBalancingStrategy
+	consecutiveFailures int
+	ejectionTime        time.Duration
+	maxEjectionPercent  int
+	onEject             func(url string)
+
+	mu        sync.Mutex
+	endpoints []*endpointState
+	next      int
+}
+
+// This creates the balancer for the urls of the request config, which is nil if there are no urls.
+func newEndpointBalancer(requestConfig *RequestConfig, onEject func(url string)) *endpointBalancer {
+	if len(requestConfig.endpoints) == 0 {
+		return nil
+	}
+	loadBalancer := requestConfig.loadBalancer
+	if loadBalancer == nil {
+		loadBalancer = &LoadBalancer{}
+	}
+	eb := &endpointBalancer{
+		strategy:            loadBalancer.strategy,
+		consecutiveFailures: loadBalancer.consecutiveFailures,
+		ejectionTime:        loadBalancer.ejectionTime,
+		maxEjectionPercent:  loadBalancer.maxEjectionPercent,
+		onEject:             onEject,
+	}
+	if eb.consecutiveFailures <= 0 {
+		eb.consecutiveFailures = defaultEjectionConsecutiveFailures
+	}
+	if eb.ejectionTime <= 0 {
+		eb.ejectionTime = defaultEjectionTime
+	}
+	if eb.maxEjectionPercent <= 0 {
+		eb.maxEjectionPercent = defaultMaxEjectionPercent
+	}
+	for _, endpoint := range requestConfig.endpoints {
+		base, err := url.Parse(endpoint.URL)
+		if err != nil {
+			// the requests to the endpoint fail with the same error
+			base = nil
+		}
+		weight := endpoint.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		eb.endpoints = append(eb.endpoints, &endpointState{url: endpoint.URL, base: base, weight: weight})
+	}
+	return eb
+}
+
+// This picks the endpoint for the request as per the strategy, among the endpoints which are not ejected and are
+// not tried yet by the request if any, and records the request in flight.
+func (eb *endpointBalancer) pick(now time.Time, tried *triedEndpoints) *endpointState {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	candidates := make([]*endpointState, 0, len(eb.endpoints))
+	for _, endpoint := range eb.endpoints {
+		if now.After(endpoint.ejectedUntil) && !tried.contains(endpoint) {
+			candidates = append(candidates, endpoint)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, endpoint := range eb.endpoints {
+			if now.After(endpoint.ejectedUntil) {
+				candidates = append(candidates, endpoint)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, eb.endpoints...)
+	}
+
+	var picked *endpointState
+	switch eb.strategy {
+	case LoadBalancingWeighted:
+		// the smooth weighted round robin, spreading the requests to an endpoint instead of sending them in a row
+		total := 0
+		for _, endpoint := range candidates {
+			endpoint.current += endpoint.weight
+			total += endpoint.weight
+			if picked == nil || endpoint.current > picked.current {
+				picked = endpoint
+			}
+		}
+		picked.current -= total
+	case LoadBalancingLeastOutstanding:
+		start := eb.next % len(candidates)
+		eb.next++
+		for i := range candidates {
+			endpoint := candidates[(start+i)%len(candidates)]
+			if picked == nil || endpoint.outstanding < picked.outstanding {
+				picked = endpoint
+			}
+		}
+	case LoadBalancingPowerOfTwoChoices:
+		picked = candidates[random.Intn(len(candidates))]
+		if len(candidates) > 1 {
+			i := random.Intn(len(candidates) - 1)
+			if candidates[i] == picked {
+				i = len(candidates) - 1
+			}
+			if candidates[i].outstanding < picked.outstanding {
+				picked = candidates[i]
+			}
+		}
+	default:
+		picked = candidates[eb.next%len(candidates)]
+		eb.next++
+	}
+	picked.outstanding++
+	tried.add(picked)
+	return picked
+}
+
+// This records the end of the request in flight to the endpoint.
+func (eb *endpointBalancer) release(endpoint *endpointState) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	endpoint.outstanding--
+}
+
+// This records the outcome of the request to the endpoint, ejecting it after the consecutive failures unless the
+// max ejection percent of the endpoints are already ejected.
+func (eb *endpointBalancer) done(endpoint *endpointState, now time.Time, failed bool) {
+	eb.mu.Lock()
+	if !failed {
+		endpoint.failures = 0
+		eb.mu.Unlock()
+		return
+	}
+	endpoint.failures++
+	ejected := false
+	if endpoint.failures >= eb.consecutiveFailures && now.After(endpoint.ejectedUntil) {
+		count := 1
+		for _, other := range eb.endpoints {
+			if now.Before(other.ejectedUntil) {
+				count++
+			}
+		}
+		if count*100 <= eb.maxEjectionPercent*len(eb.endpoints) {
+			endpoint.ejectedUntil = now.Add(eb.ejectionTime)
+			endpoint.failures = 0
+			ejected = true
+		}
+	}
+	eb.mu.Unlock()
+
+	if ejected && eb.onEject != nil {
+		eb.onEject(endpoint.url)
+	}
+}
+
+// triedEndpoints keeps the endpoints tried by the attempts of a request, so that the retries and the hedges
+// prefer the other endpoints
+type triedEndpoints struct {
+	mu        sync.Mutex
+	endpoints map[*endpointState]bool
+}
+
+// triedEndpointsKey is the context key for the endpoints tried by the request
+type triedEndpointsKey struct{}
+
+func (t *triedEndpoints) contains(endpoint *endpointState) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.endpoints[endpoint]
+}
+
+func (t *triedEndpoints) add(endpoint *endpointState) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.endpoints == nil {
+		t.endpoints = make(map[*endpointState]bool)
+	}
+	t.endpoints[endpoint] = true
+}
+
+// This gets the url of the request to the endpoint, the url of the request having only the path and query.
+func getEndpointURL(endpoint *endpointState, u *url.URL) (*url.URL, error) {
+	if endpoint.base == nil {
+		return nil, fmt.Errorf("invalid url %s", endpoint.url)
+	}
+	endpointURL := *endpoint.base
+	endpointURL.Path = strings.TrimSuffix(endpointURL.Path, "/") + u.Path
+	endpointURL.RawPath = strings.TrimSuffix(endpoint.base.EscapedPath(), "/") + u.EscapedPath()
+	if endpointURL.RawQuery == "" {
+		endpointURL.RawQuery = u.RawQuery
+	} else if u.RawQuery != "" {
+		endpointURL.RawQuery += "&" + u.RawQuery
+	}
+	return &endpointURL, nil
+}
+
+// loadBalancedClient makes every request to one of the endpoints
+type loadBalancedClient struct {
+	client   heimdall.Doer
+	balancer *endpointBalancer
+}
+
+// Do is used to make the request to the endpoint picked by the balancer
+// The request is made as is when the url is set in the Request
+func (lbc *loadBalancedClient) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "" {
+		return lbc.client.Do(req)
+	}
+	tried, _ := req.Context().Value(triedEndpointsKey{}).(*triedEndpoints)
+	endpoint := lbc.balancer.pick(time.Now(), tried)
+	endpointURL, err := getEndpointURL(endpoint, req.URL)
+	if err != nil {
+		lbc.balancer.release(endpoint)
+		lbc.balancer.done(endpoint, time.Now(), true)
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	endpointReq := req.WithContext(req.Context())
+	endpointReq.URL = endpointURL
+	endpointReq.Host = ""
+	response, err := lbc.client.Do(endpointReq)
+	// the request cancelled by the caller or lost to a hedge says nothing about the health of the endpoint
+	if !errors.Is(err, context.Canceled) {
+		lbc.balancer.done(endpoint, time.Now(), err != nil || response.StatusCode >= http.StatusInternalServerError)
+	}
+	if response == nil {
+		lbc.balancer.release(endpoint)
+		return response, err
+	}
+	// the request is outstanding until its response body is closed
+	response.Body = &releaseOnCloseBody{ReadCloser: response.Body, release: func() {
+		lbc.balancer.release(endpoint)
+	}}
+	return response, err
+}
@@ -0,0 +1,118 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBalancer(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	handler := func(name string, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name]++
+			mu.Unlock()
+			assert.Equal(t, r.URL.Path, "/api/users/a b")
+			assert.Equal(t, r.URL.Query().Get("page"), "1")
+			w.WriteHeader(status)
+		}
+	}
+	healthy := httptest.NewServer(handler("healthy", http.StatusOK))
+	defer healthy.Close()
+	failing := httptest.NewServer(handler("failing", http.StatusInternalServerError))
+	defer failing.Close()
+
+	var logs []string
+	client := ConfigureHTTPClient(NewRequestConfig("test", map[string]interface{}{
+		"method": http.MethodGet,
+		"urls": []interface{}{
+			healthy.URL + "/api",
+			map[string]interface{}{"url": failing.URL + "/api/", "weight": 1},
+		},
+		"path":       "/users/{id}",
+		"retrycount": 1,
+		"loadbalancer": map[string]interface{}{
+			"consecutivefailures": 2,
+		},
+	})).WithLogger(func(ctx context.Context, msg string) {
+		logs = append(logs, msg)
+	})
+
+	// the retry of the request failing on an endpoint is made to the other one, and the endpoint is ejected
+	// after the consecutive failures
+	for i := 0; i < 8; i++ {
+		res, err := client.Request(NewRequest("test").SetPathParam("id", "a b").SetQueryParam("page", "1"))
+		require.NoError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		require.NoError(t, res.Body.Close())
+	}
+	assert.Equal(t, hits, map[string]int{"healthy": 8, "failing": 2})
+	require.Contains(t, logs, "Ejected endpoint "+failing.URL+"/api/ of http request test")
+
+	// the url set in the request is used as is
+	res, err := client.Request(NewRequest("test").SetURL(failing.URL+"/api").SetPathParam("id", "a b").
+		SetQueryParam("page", "1"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusInternalServerError)
+	require.NoError(t, res.Body.Close())
+
+	pick := func(strategy LoadBalancingStrategy, endpoints ...Endpoint) []string {
+		balancer := newEndpointBalancer(NewRequestConfig("test", nil).SetEndpoints(endpoints...).
+			SetLoadBalancer(NewLoadBalancer(nil).SetStrategy(strategy)), nil)
+		var picked []string
+		for i := 0; i < 4; i++ {
+			picked = append(picked, balancer.pick(time.Now(), nil).url)
+		}
+		return picked
+	}
+	assert.Equal(t, pick(LoadBalancingRoundRobin, Endpoint{URL: "a"}, Endpoint{URL: "b"}), []string{"a", "b", "a", "b"})
+	assert.Equal(t, pick(LoadBalancingWeighted, Endpoint{URL: "a", Weight: 3}, Endpoint{URL: "b"}),
+		[]string{"a", "a", "b", "a"})
+	// the requests are not done, so the endpoint with fewer of them in flight is picked
+	assert.Equal(t, pick(LoadBalancingLeastOutstanding, Endpoint{URL: "a"}, Endpoint{URL: "b"})[:2],
+		[]string{"a", "b"})
+	picked := pick(LoadBalancingPowerOfTwoChoices, Endpoint{URL: "a"}, Endpoint{URL: "b"})
+	require.ElementsMatch(t, picked, []string{"a", "b", "a", "b"})
+
+	// a request is outstanding until its response body is closed, and a cancelled request does not change the
+	// failures of the endpoint
+	lbc := &loadBalancedClient{client: http.DefaultClient, balancer: newEndpointBalancer(NewRequestConfig("test", nil).
+		SetEndpoints(Endpoint{URL: failing.URL}), nil)}
+	endpoint := lbc.balancer.endpoints[0]
+	req, err := http.NewRequest(http.MethodGet, "/api/users/a%20b?page=1", nil)
+	require.NoError(t, err)
+	res, err = lbc.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, endpoint.failures, 1)
+	assert.Equal(t, endpoint.outstanding, 1)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, endpoint.outstanding, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = lbc.Do(req.WithContext(ctx))
+	require.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, endpoint.failures, 1)
+	assert.Equal(t, endpoint.outstanding, 0)
+
+	_, err = NewRequestConfigStrict("test", map[string]interface{}{
+		"method": http.MethodGet,
+		"url":    healthy.URL,
+		"urls":   []string{"localhost"},
+		"loadbalancer": map[string]interface{}{
+			"strategy": "random",
+		},
+	})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, validationErr.Error(), "invalid request config test: urls: only one of url and urls can be set; "+
+		"urls[0]: invalid url localhost; loadbalancer.strategy: unknown load balancing strategy random")
+}
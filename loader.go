@@ -0,0 +1,121 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFormat is the format of the document holding the request configurations
+type ConfigFormat string
+
+// supported configuration formats
+const (
+	FormatYAML ConfigFormat = "yaml"
+	FormatJSON ConfigFormat = "json"
+)
+
+// EndpointError is the error for a single endpoint which could not be loaded
+type EndpointError struct {
+	Name string
+	Err  error
+}
+
+// Error is used to get the error message
+func (e *EndpointError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+// Unwrap is used to get the underlying error
+func (e *EndpointError) Unwrap() error {
+	return e.Err
+}
+
+// LoadError is returned when one or more endpoints in the document could not be loaded
+type LoadError struct {
+	Errors []*EndpointError
+}
+
+// Error is used to get the error message
+func (e *LoadError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("unable to load request configs: %s", strings.Join(messages, "; "))
+}
+
+// LoadRequestConfigs is used to create the request configurations from a yaml or json document.
+// The document is a map of the request name to its configuration, and the keys are matched ignoring the case.
+// The configurations which could be loaded are returned along with a LoadError listing the ones which could not.
+func LoadRequestConfigs(r io.Reader, format ConfigFormat) ([]*RequestConfig, error) {
+	return loadRequestConfigs(r, format, false)
+}
+
+// LoadRequestConfigsStrict is same as LoadRequestConfigs, except that every configuration is created
+// using NewRequestConfigStrict, so the ones which are invalid are reported in the LoadError.
+func LoadRequestConfigsStrict(r io.Reader, format ConfigFormat) ([]*RequestConfig, error) {
+	return loadRequestConfigs(r, format, true)
+}
+
+func loadRequestConfigs(r io.Reader, format ConfigFormat, strict bool) ([]*RequestConfig, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	document := make(map[string]interface{})
+	switch ConfigFormat(strings.ToLower(string(format))) {
+	case FormatYAML, "yml":
+		err = yaml.Unmarshal(data, &document)
+	case FormatJSON:
+		err = json.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(document))
+	for name := range document {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	requestConfigs := make([]*RequestConfig, 0, len(names))
+	var loadErr *LoadError
+	for _, name := range names {
+		requestConfig, err := loadRequestConfig(name, document[name], strict)
+		if err != nil {
+			if loadErr == nil {
+				loadErr = &LoadError{}
+			}
+			loadErr.Errors = append(loadErr.Errors, &EndpointError{Name: name, Err: err})
+			continue
+		}
+		requestConfigs = append(requestConfigs, requestConfig)
+	}
+
+	if loadErr != nil {
+		return requestConfigs, loadErr
+	}
+	return requestConfigs, nil
+}
+
+func loadRequestConfig(name string, value interface{}, strict bool) (*RequestConfig, error) {
+	configMap, err := cast.ToStringMapE(value)
+	if err != nil {
+		return nil, err
+	}
+	if strict {
+		return NewRequestConfigStrict(name, configMap)
+	}
+	return NewRequestConfig(name, configMap), nil
+}
@@ -0,0 +1,52 @@
+package httpclient
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRequestConfigs(t *testing.T) {
+	document := `
+sample-call-1:
+  method: "GET"
+  url: "http://google.com"
+  timeoutInMillis: 1000
+  retryCount: 3
+  backoffPolicy:
+    constantBackoff:
+      intervalInMillis: 2
+      maxJitterIntervalInMillis: 5
+  hystrixConfig:
+    maxConcurrentRequests: 10
+    errorPercentThreshold: 20
+sample-call-2:
+  method: "POST"
+  url: "http://google.com"
+broken-call: "not a map"
+`
+	requestConfigs, err := LoadRequestConfigs(strings.NewReader(document), FormatYAML)
+	require.Error(t, err)
+
+	loadErr, ok := err.(*LoadError)
+	require.True(t, ok)
+	require.Len(t, loadErr.Errors, 1)
+	assert.Equal(t, loadErr.Errors[0].Name, "broken-call")
+
+	require.Len(t, requestConfigs, 2)
+	assert.Equal(t, requestConfigs[0].name, "sample-call-1")
+	assert.Equal(t, requestConfigs[0].timeout, time.Second)
+	assert.Equal(t, requestConfigs[0].retryCount, 3)
+	assert.Equal(t, requestConfigs[0].backoffPolicy.constantBackoff.interval, 2*time.Millisecond)
+	assert.Equal(t, requestConfigs[0].hystrixConfig.errorPercentThreshold, 20)
+	assert.Equal(t, requestConfigs[1].method, "POST")
+
+	requestConfigs, err = LoadRequestConfigs(strings.NewReader(`{"test": {"method": "GET", "timeoutInMillis": 500}}`),
+		FormatJSON)
+	require.NoError(t, err)
+	require.Len(t, requestConfigs, 1)
+	assert.Equal(t, requestConfigs[0].timeout, 500*time.Millisecond)
+}
@@ -0,0 +1,62 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// This joins the base url with the path template after replacing the {name} placeholders with the escaped path params.
+// It fails when a placeholder has no value, or when a path param has no placeholder in the template.
+func getRequestURL(baseURL, pathTemplate string, pathParams map[string]string) (string, error) {
+	if pathTemplate == "" {
+		if len(pathParams) > 0 {
+			return "", fmt.Errorf("path params %s are set without a path", strings.Join(sortedKeys(pathParams), ", "))
+		}
+		return baseURL, nil
+	}
+
+	var path strings.Builder
+	used := make(map[string]bool, len(pathParams))
+	rest := pathTemplate
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			path.WriteString(rest)
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in path %s", pathTemplate)
+		}
+		end += start
+
+		name := rest[start+1 : end]
+		value, ok := pathParams[name]
+		if !ok {
+			return "", fmt.Errorf("missing path param %s for path %s", name, pathTemplate)
+		}
+		used[name] = true
+		path.WriteString(rest[:start])
+		path.WriteString(url.PathEscape(value))
+		rest = rest[end+1:]
+	}
+
+	for _, name := range sortedKeys(pathParams) {
+		if !used[name] {
+			return "", fmt.Errorf("missing placeholder for path param %s in path %s", name, pathTemplate)
+		}
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(path.String(), "/"), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,36 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathParams(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).
+		SetURL(server.URL + "/api/").SetPath("/users/{id}/orders"))
+
+	_, err := client.Request(NewRequest("test").SetPathParam("id", "a/b c"))
+	require.NoError(t, err)
+	assert.Equal(t, path, "/api/users/a%2Fb%20c/orders")
+
+	_, err = client.Request(NewRequest("test").SetPath("/users/{id}").SetPathParams(map[string]string{"id": "42"}))
+	require.NoError(t, err)
+	assert.Equal(t, path, "/api/users/42")
+
+	_, err = client.Request(NewRequest("test"))
+	require.EqualError(t, err, "missing path param id for path /users/{id}/orders")
+
+	_, err = client.Request(NewRequest("test").SetPathParam("id", "42").SetPathParam("name", "x"))
+	require.EqualError(t, err, "missing placeholder for path param name in path /users/{id}/orders")
+}
@@ -0,0 +1,35 @@
+package httpclient
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// random is the source of the jitter of the backoffs and the picks of the load balancer, kept apart from the
+// global source so that the seed of the application is left as is
+var random = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// lockedRand is a random source safe for the concurrent use, as the sources created by rand.NewSource are not
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (lr *lockedRand) Int63() int64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Int63()
+}
+
+func (lr *lockedRand) Int63n(n int64) int64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Int63n(n)
+}
+
+func (lr *lockedRand) Intn(n int) int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Intn(n)
+}
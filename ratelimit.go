@@ -0,0 +1,209 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojek/heimdall"
+)
+
+// RateLimitMode decides what happens to the requests over the rate limit
+type RateLimitMode string
+
+// supported rate limit modes
+const (
+	// RateLimitModeWait waits for the request to be within the rate limit, failing if the context is done before
+	RateLimitModeWait RateLimitMode = "wait"
+	// RateLimitModeReject fails the request right away
+	RateLimitModeReject RateLimitMode = "reject"
+)
+
+// ErrRateLimited is matched using errors.Is by the error returned for a request which is over the rate limit
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError is the error returned for a request which is over the rate limit
+type RateLimitError struct {
+	Name string
+	Wait time.Duration
+}
+
+// Error is used to get the error message
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("request %s is rate limited, it can be made after %s", e.Name, e.Wait)
+}
+
+// Is is used to match the error with ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimiter is a token bucket limiting the requests made every second. It can be set in multiple
+// RequestConfigs, like the ones for the same host, to limit all of their requests together
+type RateLimiter struct {
+	mu     sync.Mutex
+	rps    float64
+	burst  int
+	mode   RateLimitMode
+	shared string
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter is used to create a new rate limiter
+func NewRateLimiter(configMap map[string]interface{}) *RateLimiter {
+	rateLimiter := &RateLimiter{}
+	rateLimiter.rps, _ = getConfigOptionFloat(configMap, "rps")
+	rateLimiter.burst, _ = getConfigOptionInt(configMap, "burst")
+	mode, err := getConfigOptionString(configMap, "mode")
+	if err == nil {
+		rateLimiter.mode = RateLimitMode(strings.ToLower(mode))
+	}
+	rateLimiter.shared, _ = getConfigOptionString(configMap, "shared")
+	return rateLimiter
+}
+
+// SetRPS is used to set the requests allowed every second
+func (rl *RateLimiter) SetRPS(rps float64) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.rps = rps
+	return rl
+}
+
+// SetBurst is used to set the requests allowed at once, after no requests for a while
+// if not done, then 1 is used
+func (rl *RateLimiter) SetBurst(burst int) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.burst = burst
+	return rl
+}
+
+// SetMode is used to set whether the requests over the limit wait or fail right away
+// if not done, then the requests wait
+func (rl *RateLimiter) SetMode(mode RateLimitMode) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.mode = mode
+	return rl
+}
+
+// SetShared is used to share the rate limiter with the other RequestConfigs of the Client with the same shared name
+// The settings of the RequestConfig configured last are used
+func (rl *RateLimiter) SetShared(shared string) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.shared = shared
+	return rl
+}
+
+// This copies the settings of the other rate limiter, keeping the tokens.
+func (rl *RateLimiter) update(other *RateLimiter) {
+	other.mu.Lock()
+	rps, burst, mode := other.rps, other.burst, other.mode
+	other.mu.Unlock()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.rps, rl.burst, rl.mode = rps, burst, mode
+}
+
+// This takes a token for the request, it returns the time to wait before the request can be made.
+// A request which is to be rejected or cannot be made before the deadline does not take the token.
+func (rl *RateLimiter) reserve(now time.Time, deadline time.Time) (time.Duration, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.rps <= 0 {
+		return 0, true
+	}
+	burst := float64(rl.burst)
+	if burst < 1 {
+		burst = 1
+	}
+	if rl.last.IsZero() {
+		rl.tokens = burst
+	} else if elapsed := now.Sub(rl.last); elapsed > 0 {
+		rl.tokens = math.Min(burst, rl.tokens+elapsed.Seconds()*rl.rps)
+	}
+	if now.After(rl.last) {
+		rl.last = now
+	}
+
+	wait := time.Duration(0)
+	if rl.tokens < 1 {
+		wait = time.Duration((1 - rl.tokens) / rl.rps * float64(time.Second))
+	}
+	if wait > 0 && (rl.mode == RateLimitModeReject || (!deadline.IsZero() && now.Add(wait).After(deadline))) {
+		return wait, false
+	}
+	rl.tokens--
+	return wait, true
+}
+
+// This gives back the token of a request which was not made.
+func (rl *RateLimiter) cancel() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.tokens++
+}
+
+// rateLimitedClient makes the requests within the rate limit
+type rateLimitedClient struct {
+	name    string
+	client  heimdall.Doer
+	limiter *RateLimiter
+}
+
+// Do is used to make the request once it is within the rate limit
+func (rlc *rateLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	deadline, _ := ctx.Deadline()
+	wait, ok := rlc.limiter.reserve(time.Now(), deadline)
+	if !ok {
+		closeRequestBody(req)
+		return nil, &RateLimitError{Name: rlc.name, Wait: wait}
+	}
+	if wait > 0 {
+		if err := sleep(ctx, wait); err != nil {
+			rlc.limiter.cancel()
+			closeRequestBody(req)
+			return nil, err
+		}
+	}
+	return rlc.client.Do(req)
+}
+
+// This gets the rate limiter to be used for the request config, which is the one already used by the client
+// for the same shared name if any.
+func (c *Client) getRateLimiter(requestConfig *RequestConfig) *RateLimiter {
+	rateLimiter := requestConfig.rateLimiter
+	if rateLimiter == nil {
+		return nil
+	}
+	rateLimiter.mu.Lock()
+	shared := rateLimiter.shared
+	rateLimiter.mu.Unlock()
+	if shared == "" {
+		return rateLimiter
+	}
+
+	c.rlm.Lock()
+	defer c.rlm.Unlock()
+	if c.rateLimiters == nil {
+		c.rateLimiters = make(map[string]*RateLimiter)
+	}
+	if existing, ok := c.rateLimiters[shared]; ok {
+		if existing != rateLimiter {
+			existing.update(rateLimiter)
+		}
+		return existing
+	}
+	c.rateLimiters[shared] = rateLimiter
+	return rateLimiter
+}
@@ -0,0 +1,107 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	requestConfig := func(name string, rateLimit map[string]interface{}) *RequestConfig {
+		return NewRequestConfig(name, map[string]interface{}{
+			"method":          http.MethodGet,
+			"url":             server.URL,
+			"retrycount":      2,
+			"timeoutinmillis": 200,
+			"ratelimit":       rateLimit,
+		})
+	}
+	rateLimit := map[string]interface{}{"rps": 1, "burst": 2, "mode": "reject", "shared": "host"}
+	client := ConfigureHTTPClient(requestConfig("first", rateLimit), requestConfig("second", rateLimit))
+
+	// the retries are made within the rate limit shared by the configs, and are not made once it is reached
+	_, err := client.Request(NewRequest("first"))
+	var rateLimitErr *RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	assert.Equal(t, rateLimitErr.Name, "first")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+	_, err = client.Request(NewRequest("second"))
+	require.True(t, errors.Is(err, ErrRateLimited))
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+
+	// the shared rate limiters no more used are dropped, and the ones still used keep their tokens
+	shared := client.rateLimiters["host"]
+	client.Reload(requestConfig("first", rateLimit),
+		requestConfig("third", map[string]interface{}{"rps": 1, "shared": "other"}))
+	require.Len(t, client.rateLimiters, 2)
+	assert.Equal(t, client.rateLimiters["host"] == shared, true)
+	_, err = client.Request(NewRequest("first"))
+	require.True(t, errors.Is(err, ErrRateLimited))
+	client.Remove("first")
+	require.Len(t, client.rateLimiters, 1)
+	require.NotNil(t, client.rateLimiters["other"])
+
+	// the reloads and the removals at the same time do not leave a config with a shared rate limiter of its own
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.Reload(requestConfig("first", rateLimit), requestConfig("second", rateLimit))
+		}()
+		go func() {
+			defer wg.Done()
+			client.Remove("first", "second")
+		}()
+	}
+	wg.Wait()
+	client.Upsert(requestConfig("first", rateLimit))
+	assert.Equal(t, client.httpClients["first"].rateLimiter == client.rateLimiters["host"], true)
+	if second, ok := client.httpClients["second"]; ok {
+		assert.Equal(t, second.rateLimiter == client.rateLimiters["host"], true)
+	}
+
+	// the requests wait for the rate limit, unless it is not reached before the deadline
+	rateLimiter := NewRateLimiter(nil).SetRPS(20).SetMode(RateLimitModeWait)
+	client = ConfigureHTTPClient(requestConfig("first", nil).SetRateLimiter(rateLimiter),
+		requestConfig("second", nil).SetRateLimiter(NewRateLimiter(nil).SetRPS(1)))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err = client.Request(NewRequest("first").DisableRetries())
+		require.NoError(t, err)
+	}
+	require.True(t, time.Since(start) >= 90*time.Millisecond)
+	_, err = client.Request(NewRequest("second").DisableRetries())
+	require.NoError(t, err)
+	start = time.Now()
+	_, err = client.Request(NewRequest("second").DisableRetries())
+	require.True(t, errors.Is(err, ErrRateLimited))
+	require.True(t, time.Since(start) < 100*time.Millisecond)
+
+	now := time.Now()
+	rateLimiter = NewRateLimiter(nil).SetRPS(10).SetBurst(2)
+	for _, expected := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		wait, ok := rateLimiter.reserve(now, time.Time{})
+		require.True(t, ok)
+		assert.Equal(t, wait, expected)
+	}
+	_, ok := rateLimiter.reserve(now.Add(250*time.Millisecond), now.Add(250*time.Millisecond))
+	assert.Equal(t, ok, false)
+	wait, ok := rateLimiter.reserve(now.Add(300*time.Millisecond), time.Time{})
+	require.True(t, ok)
+	assert.Equal(t, wait, 0*time.Millisecond)
+}
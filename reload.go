@@ -0,0 +1,160 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Reload is used to replace all the request configurations of the client.
+// The requests already in flight complete using the configuration they started with,
+// and the idle connections of the transports which are no longer used are closed. The bulkhead, concurrency limit,
+// retry budget and circuit breaker of a configuration keep their state if their settings did not change.
+func (c *Client) Reload(requestConfigs ...*RequestConfig) {
+	c.updateClientRequestMappings(func(httpClients map[string]ClientRequestMapping) {
+		previous := make(map[string]ClientRequestMapping, len(httpClients))
+		for name, mapping := range httpClients {
+			previous[name] = mapping
+			delete(httpClients, name)
+		}
+		c.addClientRequestMappings(httpClients, previous, requestConfigs)
+	})
+}
+
+// Upsert is used to add new request configurations to the client or replace the existing ones with the same name
+func (c *Client) Upsert(requestConfigs ...*RequestConfig) {
+	c.updateClientRequestMappings(func(httpClients map[string]ClientRequestMapping) {
+		c.addClientRequestMappings(httpClients, httpClients, requestConfigs)
+	})
+}
+
+// Remove is used to remove the request configurations with the given names from the client
+func (c *Client) Remove(names ...string) {
+	c.updateClientRequestMappings(func(httpClients map[string]ClientRequestMapping) {
+		for _, name := range names {
+			delete(httpClients, name)
+		}
+	})
+}
+
+// WatchConfigFile is used to reload the client whenever the yaml or json file at the given path changes.
+// The file is checked for changes every interval until the context is done. Every endpoint in the file
+// is created using NewRequestConfigStrict, and if any of them is invalid the reload is skipped and the
+// error is logged, so that a broken file never replaces a working configuration. The interval must be positive.
+func (c *Client) WatchConfigFile(ctx context.Context, path string, format ConfigFormat, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %s for watching http config file %s", interval, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		modTime, size := info.ModTime(), info.Size()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				c.log(ctx, fmt.Sprintf("Unable to check http config file %s for changes: %v", path, err))
+				continue
+			}
+			if info.ModTime().Equal(modTime) && info.Size() == size {
+				continue
+			}
+			modTime, size = info.ModTime(), info.Size()
+
+			err = c.reloadConfigFile(path, format)
+			if err != nil {
+				c.log(ctx, fmt.Sprintf("Unable to reload http config file %s: %v", path, err))
+				continue
+			}
+			c.log(ctx, fmt.Sprintf("Reloaded http config file %s", path))
+		}
+	}()
+
+	return nil
+}
+
+func (c *Client) reloadConfigFile(path string, format ConfigFormat) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	requestConfigs, err := LoadRequestConfigsStrict(f, format)
+	if err != nil {
+		return err
+	}
+	c.Reload(requestConfigs...)
+	return nil
+}
+
+// This copies the current mappings, applies the update on the copy and then swaps it in. The mappings are built and
+// the shared rate limiters pruned under the same lock, so that a concurrent update cannot drop the rate limiter of a
+// mapping being built.
+func (c *Client) updateClientRequestMappings(update func(map[string]ClientRequestMapping)) {
+	c.mu.Lock()
+	httpClients := make(map[string]ClientRequestMapping, len(c.httpClients))
+	for name, mapping := range c.httpClients {
+		httpClients[name] = mapping
+	}
+	update(httpClients)
+	previous := c.httpClients
+	c.httpClients = httpClients
+	c.pruneRateLimiters(httpClients)
+	c.mu.Unlock()
+
+	closeUnusedIdleConnections(previous, httpClients)
+}
+
+// This drops the shared rate limiters which are not used by the current mappings, so that the ones of the shared
+// names no more configured are not kept forever. The ones still used keep their tokens.
+func (c *Client) pruneRateLimiters(current map[string]ClientRequestMapping) {
+	inUse := make(map[*RateLimiter]bool, len(current))
+	for _, mapping := range current {
+		if mapping.rateLimiter != nil {
+			inUse[mapping.rateLimiter] = true
+		}
+	}
+
+	c.rlm.Lock()
+	defer c.rlm.Unlock()
+	for shared, rateLimiter := range c.rateLimiters {
+		if !inUse[rateLimiter] {
+			delete(c.rateLimiters, shared)
+		}
+	}
+}
+
+// This closes the idle connections of the transports in the previous mappings which are not used by the current ones.
+// Only the *http.Transport instances are closed, the transports not set in the RequestConfig default to the shared
+// http.DefaultTransport and are never closed.
+func closeUnusedIdleConnections(previous, current map[string]ClientRequestMapping) {
+	inUse := make(map[*http.Transport]bool, len(current))
+	for _, mapping := range current {
+		if transport, ok := mapping.requestConfig.transport.(*http.Transport); ok {
+			inUse[transport] = true
+		}
+	}
+
+	for _, mapping := range previous {
+		transport, ok := mapping.requestConfig.transport.(*http.Transport)
+		if !ok || transport == nil || inUse[transport] {
+			continue
+		}
+		transport.CloseIdleConnections()
+		inUse[transport] = true
+	}
+}
@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer second.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(first.URL))
+
+	res, err := client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	require.NoError(t, res.Body.Close())
+
+	client.Upsert(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(second.URL),
+		NewRequestConfig("another", nil).SetMethod(http.MethodGet).SetURL(first.URL))
+	assert.Equal(t, client.Names(), []string{"another", "test"})
+
+	res, err = client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusAccepted)
+	require.NoError(t, res.Body.Close())
+
+	client.Remove("test")
+	_, err = client.Request(NewRequest("test"))
+	require.True(t, errors.Is(err, ErrUnknownRequest))
+
+	client.Reload(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(first.URL))
+	assert.Equal(t, client.Names(), []string{"test"})
+
+	// the state is kept across the reloads for the settings which did not change
+	requestConfig := func(url string, maxConcurrentRequests int) *RequestConfig {
+		return NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(url).
+			SetHystrixConfig(NewHystrixConfig(nil)).
+			SetBulkhead(NewBulkhead(nil).SetMaxConcurrentRequests(maxConcurrentRequests)).
+			SetConcurrencyLimit(NewConcurrencyLimit(nil)).
+			SetRetryBudget(NewRetryBudget(nil))
+	}
+	client.Reload(requestConfig(first.URL, 1))
+	require.NoError(t, client.ForceCircuitOpen("test"))
+	previous := client.httpClients["test"]
+	client.Reload(requestConfig(second.URL, 1))
+	current := client.httpClients["test"]
+	assert.Equal(t, current.bulkhead == previous.bulkhead, true)
+	assert.Equal(t, current.concurrency == previous.concurrency, true)
+	assert.Equal(t, current.retryBudget == previous.retryBudget, true)
+	state, err := client.CircuitState("test")
+	require.NoError(t, err)
+	assert.Equal(t, state, CircuitOpen)
+
+	client.Upsert(requestConfig(second.URL, 2))
+	current = client.httpClients["test"]
+	assert.Equal(t, current.bulkhead == previous.bulkhead, false)
+	assert.Equal(t, current.breaker == previous.breaker, true)
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer second.Close()
+
+	var mu sync.Mutex
+	var logs []string
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(first.URL)).
+		WithLogger(func(ctx context.Context, msg string) {
+			mu.Lock()
+			logs = append(logs, msg)
+			mu.Unlock()
+		})
+	logged := func(msg string) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, log := range logs {
+				if strings.HasPrefix(log, msg) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	status := func() int {
+		res, err := client.Request(NewRequest("test"))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res.StatusCode
+	}
+
+	path := filepath.Join(t.TempDir(), "http.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"test": {"method": "GET", "url": "`+first.URL+`"}}`), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := client.WatchConfigFile(ctx, path, FormatJSON, 0)
+	require.Error(t, err)
+	err = client.WatchConfigFile(ctx, path+".missing", FormatJSON, time.Millisecond)
+	require.Error(t, err)
+	require.NoError(t, client.WatchConfigFile(ctx, path, FormatJSON, 5*time.Millisecond))
+
+	// a change of the file is picked up
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"test": {"method": "GET", "url": "`+second.URL+`"}}`), 0600))
+	require.Eventually(t, func() bool { return status() == http.StatusAccepted }, time.Second, 5*time.Millisecond)
+
+	// a broken file keeps the configuration in use
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"test": {"url": "`+first.URL+`"}}`), 0600))
+	require.Eventually(t, logged("Unable to reload http config file "+path), time.Second, 5*time.Millisecond)
+	assert.Equal(t, status(), http.StatusAccepted)
+
+	// the file is no more watched once the context is done
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"test": {"method": "GET", "url": "`+first.URL+`/"}}`), 0600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, status(), http.StatusAccepted)
+}
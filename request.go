@@ -1,8 +1,12 @@
 package httpclient
 
 import (
-	"golang.org/x/net/context"
 	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
 )
 
 // NewRequest creates a Request to execute
@@ -21,9 +25,21 @@ type Request struct {
 	ctx          context.Context
 	method       string
 	url          string
-	queryParams  map[string]string
-	headerParams map[string]string
+	path         string
+	pathParams   map[string]string
+	queryParams  url.Values
+	headerParams http.Header
 	body         io.Reader
+	jsonBody     interface{}
+	bodyFunc     func() (io.Reader, error)
+
+	removedHeaders map[string]bool
+
+	timeout       *time.Duration
+	totalTimeout  *time.Duration
+	retryCount    *int
+	backoffPolicy *BackoffPolicy
+	priority      Priority
 }
 
 // SetContext is used to set the context for the request
@@ -45,13 +61,41 @@ func (req *Request) SetURL(url string) *Request {
 	return req
 }
 
-// SetQueryParam is used to set a query param key value pair
+// SetPath is used to set the path template for the request, appended to the url
+// if not done, then the path already configured will be used
+func (req *Request) SetPath(path string) *Request {
+	req.path = path
+	return req
+}
+
+// SetPathParam is used to set the value for a placeholder like {id} in the path
+// The value is escaped, and the request fails if the path does not have the placeholder
+func (req *Request) SetPathParam(param, value string) *Request {
+	if req.pathParams == nil {
+		req.pathParams = make(map[string]string)
+	}
+	req.pathParams[param] = value
+	return req
+}
+
+// SetPathParams is used to set multiple path params - map of placeholder name and value
+func (req *Request) SetPathParams(pathParams map[string]string) *Request {
+	if req.pathParams == nil {
+		req.pathParams = make(map[string]string)
+	}
+	for k, v := range pathParams {
+		req.pathParams[k] = v
+	}
+	return req
+}
+
+// SetQueryParam is used to set a query param key value pair, replacing the values already set for the param
 // These will be passed in query param while executing HTTP request
 func (req *Request) SetQueryParam(param, value string) *Request {
 	if req.queryParams == nil {
-		req.queryParams = make(map[string]string)
+		req.queryParams = make(url.Values)
 	}
-	req.queryParams[param] = value
+	req.queryParams.Set(param, value)
 	return req
 }
 
@@ -59,21 +103,43 @@ func (req *Request) SetQueryParam(param, value string) *Request {
 // These will be passed in query param while executing HTTP request
 func (req *Request) SetQueryParams(queryParams map[string]string) *Request {
 	if req.queryParams == nil {
-		req.queryParams = make(map[string]string)
+		req.queryParams = make(url.Values)
 	}
 	for k, v := range queryParams {
-		req.queryParams[k] = v
+		req.queryParams.Set(k, v)
 	}
 	return req
 }
 
-// SetHeaderParam is used to set a header - key-value pair
+// AddQueryParam is used to add a value for a query param, keeping the values already set for the param
+// These will be passed in query param while executing HTTP request, like ?tag=a&tag=b
+func (req *Request) AddQueryParam(param, value string) *Request {
+	if req.queryParams == nil {
+		req.queryParams = make(url.Values)
+	}
+	req.queryParams.Add(param, value)
+	return req
+}
+
+// SetQueryValues is used to set multiple query params with all their values
+// These will be passed in query param while executing HTTP request
+func (req *Request) SetQueryValues(values url.Values) *Request {
+	if req.queryParams == nil {
+		req.queryParams = make(url.Values)
+	}
+	for k, v := range values {
+		req.queryParams[k] = append([]string(nil), v...)
+	}
+	return req
+}
+
+// SetHeaderParam is used to set a header - key-value pair, replacing the values already set for the header
 // These will be passed in header while executing HTTP request
 func (req *Request) SetHeaderParam(param, value string) *Request {
 	if req.headerParams == nil {
-		req.headerParams = make(map[string]string)
+		req.headerParams = make(http.Header)
 	}
-	req.headerParams[param] = value
+	req.headerParams.Set(param, value)
 	return req
 }
 
@@ -81,16 +147,110 @@ func (req *Request) SetHeaderParam(param, value string) *Request {
 // These will be passed in header while executing HTTP request
 func (req *Request) SetHeaderParams(headerParams map[string]string) *Request {
 	if req.headerParams == nil {
-		req.headerParams = make(map[string]string)
+		req.headerParams = make(http.Header)
 	}
 	for k, v := range headerParams {
-		req.headerParams[k] = v
+		req.headerParams.Set(k, v)
+	}
+	return req
+}
+
+// AddHeader is used to add a value for a header, keeping the values already set for the header
+// These will be passed in header while executing HTTP request
+func (req *Request) AddHeader(param, value string) *Request {
+	if req.headerParams == nil {
+		req.headerParams = make(http.Header)
 	}
+	req.headerParams.Add(param, value)
+	return req
+}
+
+// SetHeaders is used to set multiple headers with all their values
+// These will be passed in header while executing HTTP request
+func (req *Request) SetHeaders(headers http.Header) *Request {
+	if req.headerParams == nil {
+		req.headerParams = make(http.Header)
+	}
+	for k, v := range headers {
+		req.headerParams[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	return req
+}
+
+// RemoveHeader is used to make the request without the header, even if it is one of the configured static headers
+func (req *Request) RemoveHeader(param string) *Request {
+	param = http.CanonicalHeaderKey(param)
+	if req.removedHeaders == nil {
+		req.removedHeaders = make(map[string]bool)
+	}
+	req.removedHeaders[param] = true
+	req.headerParams.Del(param)
 	return req
 }
 
 // SetBody is used to set request body to pass in http request
 func (req *Request) SetBody(body io.Reader) *Request {
 	req.body = body
+	req.jsonBody = nil
+	req.bodyFunc = nil
+	return req
+}
+
+// SetBodyFunc is used to set the function creating the request body, called for every attempt of the request
+// This allows retrying the requests whose body cannot be kept in memory
+func (req *Request) SetBodyFunc(bodyFunc func() (io.Reader, error)) *Request {
+	req.bodyFunc = bodyFunc
+	req.body = nil
+	req.jsonBody = nil
+	return req
+}
+
+// SetJSONBody is used to set the value to be encoded as json in the request body
+// It also sets the Content-Type header to application/json
+func (req *Request) SetJSONBody(v interface{}) *Request {
+	req.jsonBody = v
+	req.body = nil
+	req.bodyFunc = nil
+	return req.SetHeaderParam(contentTypeHeader, jsonContentType)
+}
+
+// SetTimeout is used to set the timeout for every attempt of the request
+// if not done, then the timeout already configured will be used
+func (req *Request) SetTimeout(timeout time.Duration) *Request {
+	req.timeout = &timeout
+	return req
+}
+
+// SetTotalTimeout is used to set the timeout for the whole request, including all the retries and the waits between them
+// if not done, then the total timeout already configured will be used
+func (req *Request) SetTotalTimeout(totalTimeout time.Duration) *Request {
+	req.totalTimeout = &totalTimeout
+	return req
+}
+
+// SetRetryCount is used to set the retry count for the request
+// if not done, then the retry count already configured will be used, and a negative retry count is same as 0
+func (req *Request) SetRetryCount(retryCount int) *Request {
+	req.retryCount = &retryCount
 	return req
 }
+
+// SetBackoffPolicy is used to set the backoff policy for the request
+// if not done, then the backoff policy already configured will be used
+func (req *Request) SetBackoffPolicy(backoffPolicy *BackoffPolicy) *Request {
+	req.backoffPolicy = backoffPolicy
+	return req
+}
+
+// SetPriority is used to set the priority of the request, deciding the order in which the requests waiting in the
+// queue of the bulkhead are made
+// if not done, then PriorityNormal is used
+func (req *Request) SetPriority(priority Priority) *Request {
+	req.priority = priority
+	return req
+}
+
+// DisableRetries is used to make a single attempt for the request irrespective of the configured retry count
+func (req *Request) DisableRetries() *Request {
+	return req.SetRetryCount(0)
+}
@@ -1,11 +1,13 @@
 package httpclient
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/spf13/cast"
@@ -16,7 +18,11 @@ type RequestConfig struct {
 	name                  string
 	method                string
 	url                   string
+	endpoints             []Endpoint
+	loadBalancer          *LoadBalancer
+	path                  string
 	timeout               time.Duration
+	totalTimeout          time.Duration
 	connectTimeout        time.Duration
 	keepAlive             time.Duration
 	maxIdleConnections    int
@@ -26,10 +32,21 @@ type RequestConfig struct {
 	proxyURL              string
 	retryCount            int
 	backoffPolicy         *BackoffPolicy
+	retryPolicy           *RetryPolicy
+	retryBudget           *RetryBudget
+	hedgeDelay            time.Duration
+	maxHedges             int
+	hedgePercentile       float64
+	rateLimiter           *RateLimiter
+	concurrencyLimit      *ConcurrencyLimit
+	bulkhead              *Bulkhead
 	hystrixConfig         *HystrixConfig
 	transport             http.RoundTripper
 	headers               map[string]string
 	checkRedirect         func(*http.Request, []*http.Request) error
+	errorOnStatus         func(int) bool
+	fallback              func(context.Context, *Request, error) (*http.Response, error)
+	maxBodyBufferSize     int64
 }
 
 // NewRequestConfig is used to create a new request configuration from a map of configurations.
@@ -43,12 +60,19 @@ func NewRequestConfig(name string, configMap map[string]interface{}) *RequestCon
 
 		rc.method, _ = getConfigOptionString(configMap, "method")
 		rc.url, _ = getConfigOptionString(configMap, "url")
+		rc.endpoints, _ = getConfigOptionEndpoints(configMap, "urls")
+		rc.path, _ = getConfigOptionString(configMap, "path")
 
 		timeout, err := getConfigOptionInt(configMap, "timeoutinmillis")
 		if err == nil {
 			rc.timeout = time.Duration(timeout) * time.Millisecond
 		}
 
+		totalTimeout, err := getConfigOptionInt(configMap, "totaltimeoutinmillis")
+		if err == nil {
+			rc.totalTimeout = time.Duration(totalTimeout) * time.Millisecond
+		}
+
 		connectTimeout, err := getConfigOptionInt(configMap, "connecttimeoutinmillis")
 		if err == nil {
 			rc.connectTimeout = time.Duration(connectTimeout) * time.Millisecond
@@ -89,6 +113,11 @@ func NewRequestConfig(name string, configMap map[string]interface{}) *RequestCon
 
 		rc.proxyURL, _ = getConfigOptionString(configMap, "proxyurl")
 
+		maxBodyBufferSize, err := getConfigOptionInt(configMap, "maxbodybuffersizeinbytes")
+		if err == nil {
+			rc.maxBodyBufferSize = int64(maxBodyBufferSize)
+		}
+
 		rc.retryCount, err = getConfigOptionInt(configMap, "retrycount")
 		if err != nil {
 			rc.retryCount = 1
@@ -99,6 +128,44 @@ func NewRequestConfig(name string, configMap map[string]interface{}) *RequestCon
 			rc.backoffPolicy = NewBackoffPolicy(backoffPolicyMap)
 		}
 
+		retryPolicyMap, err := getConfigOptionMap(configMap, "retrypolicy")
+		if err == nil {
+			rc.retryPolicy = NewRetryPolicy(retryPolicyMap)
+		}
+
+		retryBudgetMap, err := getConfigOptionMap(configMap, "retrybudget")
+		if err == nil {
+			rc.retryBudget = NewRetryBudget(retryBudgetMap)
+		}
+
+		hedgingMap, err := getConfigOptionMap(configMap, "hedging")
+		if err == nil {
+			hedgeDelay, _ := getConfigOptionInt(hedgingMap, "delayinmillis")
+			maxHedges, _ := getConfigOptionInt(hedgingMap, "maxhedges")
+			rc.SetHedging(time.Duration(hedgeDelay)*time.Millisecond, maxHedges)
+			rc.hedgePercentile, _ = getConfigOptionFloat(hedgingMap, "percentile")
+		}
+
+		rateLimitMap, err := getConfigOptionMap(configMap, "ratelimit")
+		if err == nil {
+			rc.rateLimiter = NewRateLimiter(rateLimitMap)
+		}
+
+		concurrencyLimitMap, err := getConfigOptionMap(configMap, "concurrencylimit")
+		if err == nil {
+			rc.concurrencyLimit = NewConcurrencyLimit(concurrencyLimitMap)
+		}
+
+		bulkheadMap, err := getConfigOptionMap(configMap, "bulkhead")
+		if err == nil {
+			rc.bulkhead = NewBulkhead(bulkheadMap)
+		}
+
+		loadBalancerMap, err := getConfigOptionMap(configMap, "loadbalancer")
+		if err == nil {
+			rc.loadBalancer = NewLoadBalancer(loadBalancerMap)
+		}
+
 		hystrixConfig, err := getConfigOptionMap(configMap, "hystrixconfig")
 		if err == nil {
 			rc.hystrixConfig = NewHystrixConfig(hystrixConfig)
@@ -158,12 +225,42 @@ func (rc *RequestConfig) SetMethod(method string) *RequestConfig {
 	return rc
 }
 
-// SetURL is used to set the url for request
+// SetURL is used to set the url for request, which is used as the base url when a path is set
 func (rc *RequestConfig) SetURL(url string) *RequestConfig {
 	rc.url = url
 	return rc
 }
 
+// SetURLs is used to set multiple endpoints to balance the requests across, used instead of the url
+func (rc *RequestConfig) SetURLs(urls ...string) *RequestConfig {
+	rc.endpoints = make([]Endpoint, 0, len(urls))
+	for _, u := range urls {
+		rc.endpoints = append(rc.endpoints, Endpoint{URL: u})
+	}
+	return rc
+}
+
+// SetEndpoints is used to set multiple endpoints with their weights to balance the requests across,
+// used instead of the url
+func (rc *RequestConfig) SetEndpoints(endpoints ...Endpoint) *RequestConfig {
+	rc.endpoints = append([]Endpoint(nil), endpoints...)
+	return rc
+}
+
+// SetLoadBalancer is used to set how the requests are balanced across the endpoints
+// if not done, then round robin is used
+func (rc *RequestConfig) SetLoadBalancer(loadBalancer *LoadBalancer) *RequestConfig {
+	rc.loadBalancer = loadBalancer
+	return rc
+}
+
+// SetPath is used to set the path template for request, appended to the url
+// The placeholders like {id} in the path are replaced by the path params set in the Request
+func (rc *RequestConfig) SetPath(path string) *RequestConfig {
+	rc.path = path
+	return rc
+}
+
 // SetProxy is used to set the proxy url for request
 func (rc *RequestConfig) SetProxy(proxyURL string) *RequestConfig {
 	rc.proxyURL = proxyURL
@@ -176,6 +273,14 @@ func (rc *RequestConfig) SetTimeout(timeout time.Duration) *RequestConfig {
 	return rc
 }
 
+// SetTotalTimeout is used to set the timeout for the whole request, including all the retries and the waits between them
+// the retries which would not complete within the timeout before it are not made
+// if not done, then only the timeout for every attempt applies
+func (rc *RequestConfig) SetTotalTimeout(totalTimeout time.Duration) *RequestConfig {
+	rc.totalTimeout = totalTimeout
+	return rc
+}
+
 // SetConnectTimeout is used to set connect timeout
 func (rc *RequestConfig) SetConnectTimeout(connectTimeout time.Duration) *RequestConfig {
 	rc.connectTimeout = connectTimeout
@@ -224,6 +329,56 @@ func (rc *RequestConfig) SetBackoffPolicy(backoffPolicy *BackoffPolicy) *Request
 	return rc
 }
 
+// SetRetryPolicy is used to set the retry policy deciding which attempts of the request are retried
+func (rc *RequestConfig) SetRetryPolicy(retryPolicy *RetryPolicy) *RequestConfig {
+	rc.retryPolicy = retryPolicy
+	return rc
+}
+
+// SetRetryBudget is used to limit the retries of all the requests made using this config
+// When the budget is exhausted, the attempt which failed is returned without retrying it
+func (rc *RequestConfig) SetRetryBudget(retryBudget *RetryBudget) *RequestConfig {
+	rc.retryBudget = retryBudget
+	return rc
+}
+
+// SetHedging is used to send up to maxHedges more requests, one every delay, while none of the requests sent has
+// succeeded, returning the first successful response. Only the GET and HEAD requests without a body are hedged,
+// and only when the delay is positive
+func (rc *RequestConfig) SetHedging(delay time.Duration, maxHedges int) *RequestConfig {
+	rc.hedgeDelay = delay
+	rc.maxHedges = maxHedges
+	return rc
+}
+
+// SetHedgingPercentile is used to send the hedges after the percentile, like 95, of the recent latencies
+// The delay set using SetHedging is used until there are enough latencies, and as the least delay after that
+func (rc *RequestConfig) SetHedgingPercentile(percentile float64) *RequestConfig {
+	rc.hedgePercentile = percentile
+	return rc
+}
+
+// SetRateLimiter is used to limit the requests made every second using this config
+// The same rate limiter can be set in multiple configs, like the ones for the same host, to limit them together
+func (rc *RequestConfig) SetRateLimiter(rateLimiter *RateLimiter) *RequestConfig {
+	rc.rateLimiter = rateLimiter
+	return rc
+}
+
+// SetConcurrencyLimit is used to limit the requests in flight using this config, adapting the limit to their latency
+// and errors. The requests over the limit fail right away
+func (rc *RequestConfig) SetConcurrencyLimit(concurrencyLimit *ConcurrencyLimit) *RequestConfig {
+	rc.concurrencyLimit = concurrencyLimit
+	return rc
+}
+
+// SetBulkhead is used to limit the requests in flight using this config, making the requests over the limit wait
+// in a queue in the order of their priority
+func (rc *RequestConfig) SetBulkhead(bulkhead *Bulkhead) *RequestConfig {
+	rc.bulkhead = bulkhead
+	return rc
+}
+
 // SetHystrixConfig is used to set the hystrix config for the request
 func (rc *RequestConfig) SetHystrixConfig(hystrixConfig *HystrixConfig) *RequestConfig {
 	rc.hystrixConfig = hystrixConfig
@@ -238,6 +393,16 @@ func (rc *RequestConfig) SetHystrixFallback(fallbackFn func(error) error) *Reque
 	return rc
 }
 
+// SetFallback is used to set the function called with the error when the request fails after all the retries,
+// with or without the hystrix config. A 5xx response left after the retries, or a response with a status code which
+// is an error as per SetErrorOnStatus, fails with a *StatusError. The response returned by it, like a cached or
+// default one, is served instead
+func (rc *RequestConfig) SetFallback(
+	fallback func(ctx context.Context, request *Request, err error) (*http.Response, error)) *RequestConfig {
+	rc.fallback = fallback
+	return rc
+}
+
 // Transport is used to get the transport set for Request config.
 func (rc *RequestConfig) Transport() http.RoundTripper {
 	return rc.transport
@@ -255,6 +420,21 @@ func (rc *RequestConfig) SetCheckRedirect(checkRedirect func(*http.Request, []*h
 	return rc
 }
 
+// SetErrorOnStatus is used to set the function deciding which status codes are errors
+// For such responses the body is closed and a StatusError is returned by the client along with the response
+func (rc *RequestConfig) SetErrorOnStatus(errorOnStatus func(int) bool) *RequestConfig {
+	rc.errorOnStatus = errorOnStatus
+	return rc
+}
+
+// SetMaxBodyBufferSize is used to set the maximum size of the request body kept in memory for retrying the request
+// Zero means the default of 4 MiB and a negative size means no limit. The bodies which can be created again, like the ones set using Request.SetBodyFunc
+// or the ones which can be seeked, are never kept in memory.
+func (rc *RequestConfig) SetMaxBodyBufferSize(maxBodyBufferSize int64) *RequestConfig {
+	rc.maxBodyBufferSize = maxBodyBufferSize
+	return rc
+}
+
 // SetHeaders is used to set the headers. Setting the headers overrides the default header
 func (rc *RequestConfig) SetHeaderParams(headers map[string]interface{}) *RequestConfig {
 	rc.headers = cast.ToStringMapString(headers)
@@ -265,7 +445,7 @@ func getConfigOptionInt(options map[string]interface{}, key string) (int, error)
 	var val interface{}
 	var ok bool
 	var s int
-	if val, ok = options[key]; ok {
+	if val, ok = lookupConfigOption(options, key); ok {
 		return cast.ToIntE(val)
 	} else {
 		return s, fmt.Errorf("missing %s", key)
@@ -276,7 +456,7 @@ func getConfigOptionFloat(options map[string]interface{}, key string) (float64,
 	var val interface{}
 	var ok bool
 	var s float64
-	if val, ok = options[key]; ok {
+	if val, ok = lookupConfigOption(options, key); ok {
 		return cast.ToFloat64E(val)
 	} else {
 		return s, fmt.Errorf("missing %s", key)
@@ -287,20 +467,78 @@ func getConfigOptionMap(options map[string]interface{}, key string) (map[string]
 	var val interface{}
 	var ok bool
 	var s map[string]interface{}
-	if val, ok = options[key]; ok {
+	if val, ok = lookupConfigOption(options, key); ok {
 		return cast.ToStringMapE(val)
 	} else {
 		return s, fmt.Errorf("missing %s", key)
 	}
 }
 
+func getConfigOptionIntSlice(options map[string]interface{}, key string) ([]int, error) {
+	var val interface{}
+	var ok bool
+	var s []int
+	if val, ok = lookupConfigOption(options, key); ok {
+		return cast.ToIntSliceE(val)
+	} else {
+		return s, fmt.Errorf("missing %s", key)
+	}
+}
+
+func getConfigOptionStringSlice(options map[string]interface{}, key string) ([]string, error) {
+	var val interface{}
+	var ok bool
+	var s []string
+	if val, ok = lookupConfigOption(options, key); ok {
+		return cast.ToStringSliceE(val)
+	} else {
+		return s, fmt.Errorf("missing %s", key)
+	}
+}
+
+func getConfigOptionBool(options map[string]interface{}, key string) (bool, error) {
+	var val interface{}
+	var ok bool
+	var s bool
+	if val, ok = lookupConfigOption(options, key); ok {
+		return cast.ToBoolE(val)
+	} else {
+		return s, fmt.Errorf("missing %s", key)
+	}
+}
+
+func getConfigOptionEndpoints(options map[string]interface{}, key string) ([]Endpoint, error) {
+	var val interface{}
+	var ok bool
+	var s []Endpoint
+	if val, ok = lookupConfigOption(options, key); ok {
+		return toEndpointsE(val)
+	} else {
+		return s, fmt.Errorf("missing %s", key)
+	}
+}
+
 func getConfigOptionString(options map[string]interface{}, key string) (string, error) {
 	var val interface{}
 	var ok bool
 	var s string
-	if val, ok = options[key]; ok {
+	if val, ok = lookupConfigOption(options, key); ok {
 		return cast.ToStringE(val)
 	} else {
 		return s, fmt.Errorf("missing %s", key)
 	}
 }
+
+// This looks up the key in the options, first by exact match and then ignoring the case,
+// so that camel cased keys like timeoutInMillis used in yaml files are honoured as well.
+func lookupConfigOption(options map[string]interface{}, key string) (interface{}, bool) {
+	if val, ok := options[key]; ok {
+		return val, true
+	}
+	for k, val := range options {
+		if strings.EqualFold(k, key) {
+			return val, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,186 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// This executes the request using the client of the mapping, which makes a single attempt.
+// The request is retried as per the RetryPolicy and the RetryBudget, using the timeout, total timeout, retry count
+// and backoff policy set in the Request or else the ones configured in the RequestConfig. The retries which cannot
+// complete within their timeout, or start when there is no timeout, before the total timeout or the deadline of the
+// context are not made. The retries are noted in the metric.
+func (c *Client) execute(client ClientRequestMapping, request *Request, req *http.Request,
+	metric *Metric) (*http.Response, error) {
+	timeout := client.requestConfig.timeout
+	if request.timeout != nil {
+		timeout = *request.timeout
+	}
+	totalTimeout := client.requestConfig.totalTimeout
+	if request.totalTimeout != nil {
+		totalTimeout = *request.totalTimeout
+	}
+	retryCount := client.requestConfig.retryCount
+	if request.retryCount != nil {
+		retryCount = *request.retryCount
+	}
+	if retryCount < 0 || !client.requestConfig.retryPolicy.allowsRetry(req.Method) {
+		retryCount = 0
+	}
+	backoffPolicy := client.requestConfig.backoffPolicy
+	if request.backoffPolicy != nil {
+		backoffPolicy = request.backoffPolicy
+	}
+	backoff := backoffPolicy.getBackoff()
+
+	body, err := newRequestBody(request, req, retryCount, client.requestConfig.maxBodyBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	defer body.release()
+
+	// the total timeout bounds all the attempts and the waits between them, and is released with the response body
+	ctx, cancelTotal := getTotalContext(req.Context(), totalTimeout)
+	defer func() {
+		if cancelTotal != nil {
+			cancelTotal()
+		}
+	}()
+
+	client.retryBudget.deposit(time.Now())
+	var response *http.Response
+	for i := 0; i <= retryCount; i++ {
+		attemptReq, cancel, err := getAttemptRequest(ctx, req, body, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err = c.do(client, attemptReq, body, metric)
+		retry := i < retryCount && client.requestConfig.retryPolicy.shouldRetry(response, err)
+
+		// the attempt which failed is retried after the wait asked by the server if any, or else the backoff
+		var wait time.Duration
+		if retry {
+			var ok bool
+			wait, ok = backoffPolicy.retryAfter(response, time.Now())
+			if !ok {
+				wait = backoff.Next(i + 1)
+			}
+			if deadline, ok := ctx.Deadline(); ok && !fitsBefore(deadline, wait, timeout) {
+				// the retry cannot be made before the deadline, so this attempt is the final one
+				retry = false
+			}
+		}
+		if retry && !body.replayable {
+			// the body cannot be sent again, so the retry is not made and does not use up the budget
+			if response != nil {
+				_ = response.Body.Close()
+			}
+			cancel()
+			return nil, getBodyNotReplayableError(response, err)
+		}
+		if retry && !client.retryBudget.withdraw(time.Now()) {
+			// the retries of the endpoint are over the budget, so this attempt is the final one
+			retry = false
+			metric.RetriesSuppressed = true
+		}
+		if !retry {
+			if response != nil {
+				response.Body = &cancelOnCloseBody{ReadCloser: response.Body, cancel: chainCancel(cancel, cancelTotal)}
+				cancelTotal = nil
+			} else {
+				cancel()
+			}
+			return response, err
+		}
+
+		if response != nil {
+			_ = response.Body.Close()
+		}
+		cancel()
+		if err = sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		metric.Retries++
+	}
+	return response, nil
+}
+
+// This creates the request for a single attempt bounded by the timeout, the returned cancel must be called
+// once the attempt is done with.
+func getAttemptRequest(ctx context.Context, req *http.Request, body *requestBody,
+	timeout time.Duration) (*http.Request, context.CancelFunc, error) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	attemptReq := req.WithContext(ctx)
+	if body.get != nil {
+		rc, err := body.get()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = rc
+	}
+	return attemptReq, cancel, nil
+}
+
+// This checks whether an attempt made after the wait completes within its timeout before the deadline, or only
+// whether it starts before the deadline when there is no timeout.
+func fitsBefore(deadline time.Time, wait, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return time.Now().Add(wait).Before(deadline)
+	}
+	return !time.Now().Add(wait + timeout).After(deadline)
+}
+
+// This creates the context bounding all the attempts of the request by the total timeout, if set.
+func getTotalContext(ctx context.Context, totalTimeout time.Duration) (context.Context, context.CancelFunc) {
+	if totalTimeout > 0 {
+		return context.WithTimeout(ctx, totalTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func chainCancel(cancels ...context.CancelFunc) context.CancelFunc {
+	return func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+// This waits for the duration unless the context is done before.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// cancelOnCloseBody releases the context of the attempt once the response body is closed,
+// so that the timeout keeps applying while the body is being read.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close is used to close the body and release the context
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,119 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestOverrides(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodPut).SetURL(server.URL).
+		SetTimeout(20 * time.Millisecond).SetRetryCount(3))
+
+	res, err := client.Request(NewRequest("test").SetBody(strings.NewReader("body")))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusInternalServerError)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(4))
+
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.Request(NewRequest("test").SetRetryCount(1))
+	require.NoError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(2))
+
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.Request(NewRequest("test").DisableRetries())
+	require.NoError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(1))
+
+	// a negative retry count is same as no retries
+	atomic.StoreInt32(&attempts, 0)
+	res, err = client.Request(NewRequest("test").SetRetryCount(-1))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusInternalServerError)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(1))
+
+	// the backoff policy of the request is used instead of the configured one
+	client.Upsert(NewRequestConfig("backoff", nil).SetMethod(http.MethodGet).SetURL(server.URL).SetRetryCount(1).
+		SetBackoffPolicy(NewBackoffPolicy(nil).SetConstantBackoff(NewConstantBackoff(nil).SetInterval(time.Second))))
+	start := time.Now()
+	_, err = client.Request(NewRequest("backoff").SetBackoffPolicy(NewBackoffPolicy(nil).
+		SetConstantBackoff(NewConstantBackoff(nil).SetInterval(10 * time.Millisecond))))
+	require.NoError(t, err)
+	require.True(t, time.Since(start) < 500*time.Millisecond)
+	require.True(t, time.Since(start) >= 10*time.Millisecond)
+
+	_, err = client.Request(NewRequest("test").SetURL(server.URL + "/slow").DisableRetries())
+	require.Error(t, err)
+
+	res, err = client.Request(NewRequest("test").SetURL(server.URL + "/slow").DisableRetries().
+		SetTimeout(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusInternalServerError)
+}
+
+func TestTotalTimeout(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetRetryCount(5).SetTotalTimeout(250 * time.Millisecond).SetBackoffPolicy(NewBackoffPolicy(nil).
+		SetConstantBackoff(NewConstantBackoff(nil).SetInterval(100 * time.Millisecond))))
+
+	// the retry which cannot start before the total timeout is not made, and the last attempt is returned
+	start := time.Now()
+	res, err := client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(3))
+	require.True(t, time.Since(start) < 250*time.Millisecond)
+	_, err = ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	// the deadline of the context is respected as well
+	atomic.StoreInt32(&attempts, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	_, err = client.Request(NewRequest("test").SetContext(ctx).SetTotalTimeout(0))
+	require.NoError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(2))
+
+	_, err = client.Request(NewRequest("test").SetURL(server.URL + "/slow").SetTotalTimeout(50 * time.Millisecond))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// the retry which can start after the backoff but cannot complete within its timeout is not made either
+	atomic.StoreInt32(&attempts, 0)
+	start = time.Now()
+	res, err = client.Request(NewRequest("test").SetTimeout(200 * time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(1))
+	require.True(t, time.Since(start) < 100*time.Millisecond)
+	require.NoError(t, res.Body.Close())
+}
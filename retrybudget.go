@@ -0,0 +1,140 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryBudgetWindow   = 10 * time.Second
+	defaultRetryBudgetRatio    = 0.1
+	defaultMinRetriesPerSecond = 10
+	retryBudgetBuckets         = 10
+)
+
+// RetryBudget is the type for limiting the retries of all the requests with the same name,
+// so that the retries do not multiply the traffic when the endpoint is failing widely
+type RetryBudget struct {
+	ratio               float64
+	minRetriesPerSecond float64
+	window              time.Duration
+}
+
+// NewRetryBudget is used to create a new retry budget
+func NewRetryBudget(configMap map[string]interface{}) *RetryBudget {
+	retryBudget := &RetryBudget{}
+	retryBudget.ratio, _ = getConfigOptionFloat(configMap, "ratio")
+	retryBudget.minRetriesPerSecond, _ = getConfigOptionFloat(configMap, "minretriespersecond")
+	window, err := getConfigOptionInt(configMap, "windowinmillis")
+	if err == nil {
+		retryBudget.window = time.Duration(window) * time.Millisecond
+	}
+	return retryBudget
+}
+
+// SetRatio is used to set the retries allowed for every request, like 0.1 for retries up to 10% of the requests
+// if neither this nor the min retries per second is set, then 0.1 is used
+func (rb *RetryBudget) SetRatio(ratio float64) *RetryBudget {
+	rb.ratio = ratio
+	return rb
+}
+
+// SetMinRetriesPerSecond is used to set the retries always allowed every second, irrespective of the ratio
+// This allows the retries when there are only a few requests
+// if neither this nor the ratio is set, then 10 is used
+func (rb *RetryBudget) SetMinRetriesPerSecond(minRetriesPerSecond float64) *RetryBudget {
+	rb.minRetriesPerSecond = minRetriesPerSecond
+	return rb
+}
+
+// SetWindow is used to set the duration over which the requests and the retries are counted
+// if not done, then 10 seconds is used
+func (rb *RetryBudget) SetWindow(window time.Duration) *RetryBudget {
+	rb.window = window
+	return rb
+}
+
+// retryBudgetBucket counts the requests and the retries made in a part of the window
+type retryBudgetBucket struct {
+	epoch    int64
+	requests float64
+	retries  float64
+}
+
+// retryBudgetTracker keeps the requests and the retries made in the sliding window of the retry budget
+type retryBudgetTracker struct {
+	ratio      float64
+	minRetries float64
+	bucketSize time.Duration
+
+	mu      sync.Mutex
+	buckets [retryBudgetBuckets]retryBudgetBucket
+}
+
+// This creates the tracker for the retry budget, which is nil if there is no retry budget.
+func newRetryBudgetTracker(retryBudget *RetryBudget) *retryBudgetTracker {
+	if retryBudget == nil {
+		return nil
+	}
+	window := retryBudget.window
+	if window <= 0 {
+		window = defaultRetryBudgetWindow
+	}
+	bucketSize := window / retryBudgetBuckets
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	// a budget with neither set would not allow any retry
+	ratio, minRetriesPerSecond := retryBudget.ratio, retryBudget.minRetriesPerSecond
+	if ratio <= 0 && minRetriesPerSecond <= 0 {
+		ratio, minRetriesPerSecond = defaultRetryBudgetRatio, defaultMinRetriesPerSecond
+	}
+	return &retryBudgetTracker{
+		ratio:      ratio,
+		minRetries: minRetriesPerSecond * window.Seconds(),
+		bucketSize: bucketSize,
+	}
+}
+
+// This records a request, which adds to the retries allowed.
+func (t *retryBudgetTracker) deposit(now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bucket(now).requests++
+}
+
+// This records a retry if it is allowed by the budget, and returns whether it is allowed.
+func (t *retryBudgetTracker) withdraw(now time.Time) bool {
+	if t == nil {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket := t.bucket(now)
+	var requests, retries float64
+	for i := range t.buckets {
+		if t.buckets[i].epoch > bucket.epoch-retryBudgetBuckets {
+			requests += t.buckets[i].requests
+			retries += t.buckets[i].retries
+		}
+	}
+	if retries+1 > requests*t.ratio+t.minRetries {
+		return false
+	}
+	bucket.retries++
+	return true
+}
+
+// This gets the bucket for the time, resetting it if it belongs to an older window.
+func (t *retryBudgetTracker) bucket(now time.Time) *retryBudgetBucket {
+	epoch := now.UnixNano() / int64(t.bucketSize)
+	bucket := &t.buckets[epoch%retryBudgetBuckets]
+	if bucket.epoch != epoch {
+		*bucket = retryBudgetBucket{epoch: epoch}
+	}
+	return bucket
+}
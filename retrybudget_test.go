@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newRetryBudgetTracker(NewRetryBudget(map[string]interface{}{
+		"ratio":          0.5,
+		"windowinmillis": 1000,
+	}))
+	for i := 0; i < 4; i++ {
+		tracker.deposit(now)
+	}
+	assert.Equal(t, tracker.withdraw(now), true)
+	assert.Equal(t, tracker.withdraw(now.Add(500*time.Millisecond)), true)
+	assert.Equal(t, tracker.withdraw(now.Add(500*time.Millisecond)), false)
+	// the requests and the retries out of the window are not counted
+	tracker.deposit(now.Add(1500 * time.Millisecond))
+	tracker.deposit(now.Add(1500 * time.Millisecond))
+	assert.Equal(t, tracker.withdraw(now.Add(1500*time.Millisecond)), true)
+	assert.Equal(t, tracker.withdraw(now.Add(1500*time.Millisecond)), false)
+
+	// a budget without the ratio and the min retries per second uses the defaults instead of allowing no retries
+	tracker = newRetryBudgetTracker(NewRetryBudget(nil))
+	assert.Equal(t, tracker.ratio, 0.1)
+	assert.Equal(t, tracker.minRetries, 100.0)
+	assert.Equal(t, tracker.withdraw(now), true)
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var metrics []Metric
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetRetryCount(3).SetRetryBudget(NewRetryBudget(nil).SetMinRetriesPerSecond(0.2))).
+		WithMetrics(func(ctx context.Context, name string, m Metric) {
+			metrics = append(metrics, m)
+		})
+
+	res, err := client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(3))
+
+	_, err = client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(4))
+
+	require.Len(t, metrics, 2)
+	assert.Equal(t, metrics[0].Retries, 2)
+	assert.Equal(t, metrics[0].RetriesSuppressed, true)
+	assert.Equal(t, metrics[1].Retries, 0)
+	assert.Equal(t, metrics[1].RetriesSuppressed, true)
+
+	// the metric of a request failing without a response is reported when its retries are suppressed
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+	client.Upsert(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(closed.URL).SetRetryCount(3).
+		SetRetryBudget(NewRetryBudget(nil).SetMinRetriesPerSecond(0.1)))
+	_, err = client.Request(NewRequest("test"))
+	require.Error(t, err)
+	require.Len(t, metrics, 3)
+	assert.Equal(t, metrics[2].Status, 0)
+	assert.Equal(t, metrics[2].Retries, 1)
+	assert.Equal(t, metrics[2].RetriesSuppressed, true)
+}
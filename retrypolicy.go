@@ -0,0 +1,175 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// ErrorClass is the class of errors which can be retried
+type ErrorClass string
+
+// supported error classes
+const (
+	ErrorClassTimeout           ErrorClass = "timeout"
+	ErrorClassConnectionReset   ErrorClass = "connectionreset"
+	ErrorClassConnectionRefused ErrorClass = "connectionrefused"
+	ErrorClassDNS               ErrorClass = "dns"
+)
+
+// RetryPolicy is the type for deciding which attempts of a request are retried
+type RetryPolicy struct {
+	statusCodes    map[int]bool
+	errorClasses   map[ErrorClass]bool
+	predicate      func(*http.Response, error) bool
+	nonIdempotent  bool
+	idempotencyKey bool
+}
+
+// NewRetryPolicy is used to create a new retry policy
+func NewRetryPolicy(configMap map[string]interface{}) *RetryPolicy {
+	retryPolicy := &RetryPolicy{}
+
+	statusCodes, err := getConfigOptionIntSlice(configMap, "statuscodes")
+	if err == nil {
+		retryPolicy.SetStatusCodes(statusCodes...)
+	}
+
+	errorClasses, err := getConfigOptionStringSlice(configMap, "errors")
+	if err == nil {
+		for _, errorClass := range errorClasses {
+			retryPolicy.SetErrorClasses(ErrorClass(errorClass))
+		}
+	}
+
+	retryPolicy.nonIdempotent, _ = getConfigOptionBool(configMap, "retrynonidempotent")
+	retryPolicy.idempotencyKey, _ = getConfigOptionBool(configMap, "idempotencykey")
+
+	return retryPolicy
+}
+
+// SetStatusCodes is used to add the status codes to be retried
+// if not done, then the responses with status code 5xx are retried
+func (rp *RetryPolicy) SetStatusCodes(statusCodes ...int) *RetryPolicy {
+	if rp.statusCodes == nil {
+		rp.statusCodes = make(map[int]bool)
+	}
+	for _, statusCode := range statusCodes {
+		rp.statusCodes[statusCode] = true
+	}
+	return rp
+}
+
+// SetErrorClasses is used to add the classes of errors to be retried
+// if not done, then all the errors are retried
+func (rp *RetryPolicy) SetErrorClasses(errorClasses ...ErrorClass) *RetryPolicy {
+	if rp.errorClasses == nil {
+		rp.errorClasses = make(map[ErrorClass]bool)
+	}
+	for _, errorClass := range errorClasses {
+		rp.errorClasses[errorClass] = true
+	}
+	return rp
+}
+
+// SetPredicate is used to set the function deciding whether an attempt is retried
+// If set, it alone decides, and the status codes and error classes are not used
+func (rp *RetryPolicy) SetPredicate(predicate func(*http.Response, error) bool) *RetryPolicy {
+	rp.predicate = predicate
+	return rp
+}
+
+// SetRetryNonIdempotent is used to retry the requests with non idempotent methods like POST and PATCH as well
+// if not done, then only the requests with idempotent methods are retried
+func (rp *RetryPolicy) SetRetryNonIdempotent(retryNonIdempotent bool) *RetryPolicy {
+	rp.nonIdempotent = retryNonIdempotent
+	return rp
+}
+
+// SetIdempotencyKey is used to send an Idempotency-Key header with the requests having non idempotent methods,
+// which is the same for all the attempts of a request, making it safe to retry them for the servers supporting it
+// The header is not generated if already set in the Request
+func (rp *RetryPolicy) SetIdempotencyKey(idempotencyKey bool) *RetryPolicy {
+	rp.idempotencyKey = idempotencyKey
+	return rp
+}
+
+// This decides whether the requests with the method can be retried at all.
+// A nil policy retries only the idempotent methods.
+func (rp *RetryPolicy) allowsRetry(method string) bool {
+	if isIdempotent(method) {
+		return true
+	}
+	return rp != nil && (rp.nonIdempotent || rp.idempotencyKey)
+}
+
+// This decides whether the idempotency key header is to be generated for the requests with the method.
+func (rp *RetryPolicy) needsIdempotencyKey(method string) bool {
+	return rp != nil && rp.idempotencyKey && !isIdempotent(method)
+}
+
+func isIdempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// This decides whether the attempt with the response or error is to be retried.
+// A nil policy retries all the errors and the responses with status code 5xx, except when the circuit is open
+// or the request is rate or concurrency limited or is not admitted by the bulkhead.
+func (rp *RetryPolicy) shouldRetry(response *http.Response, err error) bool {
+	if rp != nil && rp.predicate != nil {
+		return rp.predicate(response, err)
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		// the retries would not be made either until the sleep window is over
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrConcurrencyLimited) || errors.Is(err, ErrBulkheadFull) ||
+		errors.Is(err, ErrBulkheadTimeout) {
+		// the request could not be made within the limit, the retries would only add to the load
+		return false
+	}
+	if err != nil {
+		if rp == nil || rp.errorClasses == nil {
+			return true
+		}
+		errorClass, ok := getErrorClass(err)
+		return ok && rp.errorClasses[errorClass]
+	}
+	if response == nil {
+		return true
+	}
+	if rp == nil || rp.statusCodes == nil {
+		// the server asking to retry after a while is retried as well
+		return response.StatusCode >= http.StatusInternalServerError ||
+			(response.StatusCode == http.StatusTooManyRequests && response.Header.Get(retryAfterHeader) != "")
+	}
+	return rp.statusCodes[response.StatusCode]
+}
+
+// This finds the class of the error, if it is one of the supported ones.
+func getErrorClass(err error) (ErrorClass, bool) {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorClassDNS, true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorClassConnectionRefused, true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassConnectionReset, true
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorClassTimeout, true
+	}
+	return "", false
+}
@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1)%3 != 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	url := server.URL
+
+	requestConfig, err := NewRequestConfigStrict("test", map[string]interface{}{
+		"method":     http.MethodGet,
+		"url":        url,
+		"retrycount": 3,
+		"retryPolicy": map[string]interface{}{
+			"statusCodes": []interface{}{429, 503},
+			"errors":      []interface{}{"connectionrefused"},
+		},
+	})
+	require.NoError(t, err)
+	client := ConfigureHTTPClient(requestConfig, NewRequestConfig("default", nil).SetMethod(http.MethodGet).
+		SetURL(url).SetRetryCount(3))
+
+	res, err := client.Request(NewRequest("test"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(3))
+
+	// the default policy only retries the 5xx status codes, and 429 when the server asks to retry after a while
+	res, err = client.Request(NewRequest("default"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusTooManyRequests)
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(4))
+
+	var laterAttempts int32
+	later := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&laterAttempts, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer later.Close()
+	res, err = client.Request(NewRequest("default").SetURL(later.URL))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, atomic.LoadInt32(&laterAttempts), int32(2))
+
+	server.Close()
+	_, err = client.Request(NewRequest("test"))
+	errorClass, ok := getErrorClass(err)
+	require.True(t, ok)
+	assert.Equal(t, errorClass, ErrorClassConnectionRefused)
+
+	var predicateCalls int32
+	client.Upsert(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(url).SetRetryCount(3).
+		SetRetryPolicy(NewRetryPolicy(nil).SetPredicate(func(res *http.Response, err error) bool {
+			atomic.AddInt32(&predicateCalls, 1)
+			return false
+		})))
+	_, err = client.Request(NewRequest("test"))
+	require.Error(t, err)
+	assert.Equal(t, atomic.LoadInt32(&predicateCalls), int32(1))
+
+	_, err = NewRequestConfigStrict("test", map[string]interface{}{
+		"method":      http.MethodGet,
+		"url":         url,
+		"retrypolicy": map[string]interface{}{"statuscodes": []interface{}{42}, "errors": []interface{}{"unknown"}},
+	})
+	require.EqualError(t, err,
+		"invalid request config test: retrypolicy.statuscodes: invalid status code 42; retrypolicy.errors: unknown error class unknown")
+}
@@ -0,0 +1,37 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// StatusError is the error for a response with an unexpected status code.
+// It carries the status code, the headers and the beginning of the body of the response.
+type StatusError struct {
+	Name       string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Error is used to get the error message
+func (e *StatusError) Error() string {
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("http request %s failed with status %d", e.Name, e.StatusCode)
+	}
+	return fmt.Sprintf("http request %s failed with status %d: %s", e.Name, e.StatusCode, e.Body)
+}
+
+// This creates the StatusError for the response, reading at most maxStatusErrorBodySize bytes of the body.
+// The body is not closed, that is left to the caller.
+func newStatusError(name string, response *http.Response) *StatusError {
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, int64(maxStatusErrorBodySize)))
+	return &StatusError{
+		Name:       name,
+		StatusCode: response.StatusCode,
+		Header:     response.Header,
+		Body:       body,
+	}
+}
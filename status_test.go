@@ -0,0 +1,41 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorOnStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("X-Reason", "missing")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(strings.Repeat("a", 2*maxStatusErrorBodySize)))
+	}))
+	defer server.Close()
+
+	client := ConfigureHTTPClient(NewRequestConfig("test", nil).SetMethod(http.MethodGet).SetURL(server.URL).
+		SetErrorOnStatus(func(status int) bool { return status >= http.StatusBadRequest }))
+
+	res, err := client.Request(NewRequest("test"))
+	require.NotNil(t, res)
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, statusErr.Name, "test")
+	assert.Equal(t, statusErr.StatusCode, http.StatusNotFound)
+	assert.Equal(t, statusErr.Header.Get("X-Reason"), "missing")
+	assert.Equal(t, len(statusErr.Body), maxStatusErrorBodySize)
+
+	res, err = client.Request(NewRequest("test").SetURL(server.URL + "/ok"))
+	require.NoError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
@@ -0,0 +1,591 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// ConfigError is a single problem found while validating a configuration
+type ConfigError struct {
+	Field   string
+	Message string
+}
+
+// Error is used to get the error message
+func (e *ConfigError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError lists all the problems found while validating the configuration of a request
+type ValidationError struct {
+	Name   string
+	Errors []*ConfigError
+}
+
+// Error is used to get the error message
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("invalid request config %s: %s", e.Name, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, &ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) merge(prefix string, other *ValidationError) {
+	if other == nil {
+		return
+	}
+	for _, err := range other.Errors {
+		e.Errors = append(e.Errors, &ConfigError{Field: joinField(prefix, err.Field), Message: err.Message})
+	}
+}
+
+// this returns nil when there are no problems, so that a typed nil never escapes as a non nil error
+func (e *ValidationError) errorOrNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// NewRequestConfigStrict is used to create a new request configuration from a map of configurations.
+// Unlike NewRequestConfig it fails with a ValidationError listing the unknown keys, the values of the wrong type
+// and the problems reported by Validate.
+func NewRequestConfigStrict(name string, configMap map[string]interface{}) (*RequestConfig, error) {
+	validationErr := &ValidationError{Name: name}
+	checkConfigMap(validationErr, "", configMap, requestConfigSchema)
+	if tlsMinVersion, err := getConfigOptionString(configMap, "tlsminversion"); err == nil {
+		switch tlsMinVersion {
+		case "1.0", "1.1", "1.2", "1.3":
+		default:
+			validationErr.add("tlsminversion", "must be one of 1.0, 1.1, 1.2 and 1.3")
+		}
+	}
+
+	rc := NewRequestConfig(name, configMap)
+	if err, ok := rc.Validate().(*ValidationError); ok {
+		validationErr.merge("", err)
+	}
+
+	return rc, validationErr.errorOrNil()
+}
+
+// Validate is used to check the request configuration for missing mandatory fields and invalid values
+func (rc *RequestConfig) Validate() error {
+	validationErr := &ValidationError{Name: rc.name}
+
+	if rc.name == "" {
+		validationErr.add("name", "is mandatory")
+	}
+	if rc.method == "" {
+		validationErr.add("method", "is mandatory")
+	}
+	if rc.url == "" && len(rc.endpoints) == 0 {
+		validationErr.add("url", "is mandatory")
+	}
+	if rc.url != "" && len(rc.endpoints) > 0 {
+		validationErr.add("urls", "only one of url and urls can be set")
+	}
+	for i, endpoint := range rc.endpoints {
+		if u, err := url.Parse(endpoint.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			validationErr.add(fmt.Sprintf("urls[%d]", i), "invalid url %s", endpoint.URL)
+		}
+		checkNonNegative(validationErr, fmt.Sprintf("urls[%d].weight", i), int64(endpoint.Weight))
+	}
+	checkNonNegative(validationErr, "timeoutinmillis", int64(rc.timeout))
+	checkNonNegative(validationErr, "totaltimeoutinmillis", int64(rc.totalTimeout))
+	checkNonNegative(validationErr, "connecttimeoutinmillis", int64(rc.connectTimeout))
+	checkNonNegative(validationErr, "keepaliveinmillis", int64(rc.keepAlive))
+	checkNonNegative(validationErr, "maxidleconnections", int64(rc.maxIdleConnections))
+	checkNonNegative(validationErr, "idleconnectiontimeoutinmillis", int64(rc.idleConnectionTimeout))
+	checkNonNegative(validationErr, "tlshandshaketimeoutinmillis", int64(rc.tlsHandshakeTimeout))
+	checkNonNegative(validationErr, "expectcontinuetimeoutinmillis", int64(rc.expectContinueTimeout))
+	checkNonNegative(validationErr, "retrycount", int64(rc.retryCount))
+
+	checkNonNegative(validationErr, "hedging.delayinmillis", int64(rc.hedgeDelay))
+	checkNonNegative(validationErr, "hedging.maxhedges", int64(rc.maxHedges))
+	if rc.maxHedges > 0 && rc.hedgeDelay == 0 {
+		validationErr.add("hedging.delayinmillis", "must be positive when maxhedges is set")
+	}
+	if rc.hedgePercentile < 0 || rc.hedgePercentile > 100 {
+		validationErr.add("hedging.percentile", "must be between 0 and 100")
+	}
+
+	if rc.backoffPolicy != nil {
+		if err, ok := rc.backoffPolicy.Validate().(*ValidationError); ok {
+			validationErr.merge("backoffpolicy", err)
+		}
+	}
+	if rc.retryPolicy != nil {
+		if err, ok := rc.retryPolicy.Validate().(*ValidationError); ok {
+			validationErr.merge("retrypolicy", err)
+		}
+	}
+	if rc.retryBudget != nil {
+		if err, ok := rc.retryBudget.Validate().(*ValidationError); ok {
+			validationErr.merge("retrybudget", err)
+		}
+	}
+	if rc.rateLimiter != nil {
+		if err, ok := rc.rateLimiter.Validate().(*ValidationError); ok {
+			validationErr.merge("ratelimit", err)
+		}
+	}
+	if rc.concurrencyLimit != nil {
+		if err, ok := rc.concurrencyLimit.Validate().(*ValidationError); ok {
+			validationErr.merge("concurrencylimit", err)
+		}
+	}
+	if rc.bulkhead != nil {
+		if err, ok := rc.bulkhead.Validate().(*ValidationError); ok {
+			validationErr.merge("bulkhead", err)
+		}
+	}
+	if rc.loadBalancer != nil {
+		if err, ok := rc.loadBalancer.Validate().(*ValidationError); ok {
+			validationErr.merge("loadbalancer", err)
+		}
+	}
+	if rc.hystrixConfig != nil {
+		if err, ok := rc.hystrixConfig.Validate().(*ValidationError); ok {
+			validationErr.merge("hystrixconfig", err)
+		}
+	}
+
+	return validationErr.errorOrNil()
+}
+
+// Validate is used to check the backoff policy for conflicting strategies and invalid values
+func (bop *BackoffPolicy) Validate() error {
+	validationErr := &ValidationError{}
+
+	var configured []string
+	for _, strategy := range backoffStrategies {
+		if strategy != BackoffStrategyCustom && bop.getStrategyBackoff(strategy) != nil {
+			configured = append(configured, string(strategy)+"backoff")
+		}
+	}
+	if bop.strategy == "" && len(configured) > 1 {
+		validationErr.add("", "only one of %s can be set without strategy", strings.Join(configured, " and "))
+	}
+	if bop.strategy != "" && bop.getStrategyBackoff(bop.strategy) == nil {
+		if isBackoffStrategy(bop.strategy) {
+			validationErr.add("strategy", "%s is not configured", bop.strategy)
+		} else {
+			validationErr.add("strategy", "unknown backoff strategy %s", bop.strategy)
+		}
+	}
+	checkNonNegative(validationErr, "maxretryafterinmillis", int64(bop.maxRetryAfter))
+	if bop.constantBackoff != nil {
+		checkNonNegative(validationErr, "constantbackoff.intervalinmillis", int64(bop.constantBackoff.interval))
+		checkNonNegative(validationErr, "constantbackoff.maxjitterintervalinmillis",
+			int64(bop.constantBackoff.maximumJitterInterval))
+	}
+	if eb := bop.exponentialBackoff; eb != nil {
+		checkNonNegative(validationErr, "exponentialbackoff.initialtimeoutinmillis", int64(eb.initialTimeout))
+		checkNonNegative(validationErr, "exponentialbackoff.maxtimeoutinmillis", int64(eb.maxTimeout))
+		checkNonNegative(validationErr, "exponentialbackoff.maxjitterintervalinmillis", int64(eb.maximumJitterInterval))
+		if eb.exponentFactor < 0 {
+			validationErr.add("exponentialbackoff.exponentfactor", "must not be negative")
+		}
+		if eb.maxTimeout < eb.initialTimeout {
+			validationErr.add("exponentialbackoff.maxtimeoutinmillis", "must not be less than initialtimeoutinmillis")
+		}
+	}
+	checkJitterBackoff(validationErr, "fulljitterbackoff", bop.fullJitterBackoff)
+	checkJitterBackoff(validationErr, "equaljitterbackoff", bop.equalJitterBackoff)
+	checkJitterBackoff(validationErr, "decorrelatedjitterbackoff", bop.decorrelatedJitterBackoff)
+	if fb := bop.fibonacciBackoff; fb != nil {
+		checkNonNegative(validationErr, "fibonaccibackoff.intervalinmillis", int64(fb.interval))
+		checkNonNegative(validationErr, "fibonaccibackoff.maxtimeoutinmillis", int64(fb.maxTimeout))
+		checkNonNegative(validationErr, "fibonaccibackoff.maxjitterintervalinmillis", int64(fb.maximumJitterInterval))
+	}
+	if lb := bop.linearBackoff; lb != nil {
+		checkNonNegative(validationErr, "linearbackoff.initialtimeoutinmillis", int64(lb.initialTimeout))
+		checkNonNegative(validationErr, "linearbackoff.incrementinmillis", int64(lb.increment))
+		checkNonNegative(validationErr, "linearbackoff.maxtimeoutinmillis", int64(lb.maxTimeout))
+		checkNonNegative(validationErr, "linearbackoff.maxjitterintervalinmillis", int64(lb.maximumJitterInterval))
+	}
+
+	return validationErr.errorOrNil()
+}
+
+// Validate is used to check the retry budget for negative values
+func (rb *RetryBudget) Validate() error {
+	validationErr := &ValidationError{}
+
+	if rb.ratio < 0 {
+		validationErr.add("ratio", "must not be negative")
+	}
+	if rb.minRetriesPerSecond < 0 {
+		validationErr.add("minretriespersecond", "must not be negative")
+	}
+	checkNonNegative(validationErr, "windowinmillis", int64(rb.window))
+
+	return validationErr.errorOrNil()
+}
+
+// Validate is used to check the rate limiter for negative values and unknown modes
+func (rl *RateLimiter) Validate() error {
+	validationErr := &ValidationError{}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.rps < 0 {
+		validationErr.add("rps", "must not be negative")
+	}
+	checkNonNegative(validationErr, "burst", int64(rl.burst))
+	switch rl.mode {
+	case "", RateLimitModeWait, RateLimitModeReject:
+	default:
+		validationErr.add("mode", "must be one of wait and reject")
+	}
+
+	return validationErr.errorOrNil()
+}
+
+// Validate is used to check the concurrency limit for negative values and inconsistent limits
+func (cl *ConcurrencyLimit) Validate() error {
+	validationErr := &ValidationError{}
+
+	checkNonNegative(validationErr, "initiallimit", int64(cl.initialLimit))
+	checkNonNegative(validationErr, "minlimit", int64(cl.minLimit))
+	checkNonNegative(validationErr, "maxlimit", int64(cl.maxLimit))
+	checkNonNegative(validationErr, "latencythresholdinmillis", int64(cl.latencyThreshold))
+	if cl.maxLimit > 0 && cl.maxLimit < cl.minLimit {
+		validationErr.add("maxlimit", "must not be less than minlimit")
+	}
+	if cl.maxLimit > 0 && cl.initialLimit > cl.maxLimit {
+		validationErr.add("initiallimit", "must not be more than maxlimit")
+	}
+	if cl.initialLimit > 0 && cl.initialLimit < cl.minLimit {
+		validationErr.add("initiallimit", "must not be less than minlimit")
+	}
+	if cl.backoffRatio < 0 || cl.backoffRatio >= 1 {
+		validationErr.add("backoffratio", "must be between 0 and 1")
+	}
+
+	return validationErr.errorOrNil()
+}
+
+// Validate is used to check the bulkhead for missing and negative values
+func (b *Bulkhead) Validate() error {
+	validationErr := &ValidationError{}
+
+	if b.maxConcurrentRequests <= 0 {
+		validationErr.add("maxconcurrentrequests", "must be positive")
+	}
+	checkNonNegative(validationErr, "maxqueuesize", int64(b.maxQueueSize))
+	checkNonNegative(validationErr, "queuetimeoutinmillis", int64(b.queueTimeout))
+
+	return validationErr.errorOrNil()
+}
+
+// Validate is used to check the load balancer for unknown strategies and invalid values
+func (lb *LoadBalancer) Validate() error {
+	validationErr := &ValidationError{}
+
+	switch lb.strategy {
+	case "", LoadBalancingRoundRobin, LoadBalancingWeighted, LoadBalancingLeastOutstanding,
+		LoadBalancingPowerOfTwoChoices:
+	default:
+		validationErr.add("strategy", "unknown load balancing strategy %s", lb.strategy)
+	}
+	checkNonNegative(validationErr, "consecutivefailures", int64(lb.consecutiveFailures))
+	checkNonNegative(validationErr, "ejectiontimeinmillis", int64(lb.ejectionTime))
+	if lb.maxEjectionPercent < 0 || lb.maxEjectionPercent > 100 {
+		validationErr.add("maxejectionpercent", "must be between 0 and 100")
+	}
+
+	return validationErr.errorOrNil()
+}
+
+// Validate is used to check the retry policy for invalid status codes and unknown error classes
+func (rp *RetryPolicy) Validate() error {
+	validationErr := &ValidationError{}
+
+	for statusCode := range rp.statusCodes {
+		if statusCode < 100 || statusCode > 599 {
+			validationErr.add("statuscodes", "invalid status code %d", statusCode)
+		}
+	}
+	for errorClass := range rp.errorClasses {
+		switch errorClass {
+		case ErrorClassTimeout, ErrorClassConnectionReset, ErrorClassConnectionRefused, ErrorClassDNS:
+		default:
+			validationErr.add("errors", "unknown error class %s", errorClass)
+		}
+	}
+	sort.Slice(validationErr.Errors, func(i, j int) bool {
+		return validationErr.Errors[i].Message < validationErr.Errors[j].Message
+	})
+
+	return validationErr.errorOrNil()
+}
+
+// Validate is used to check the hystrix configuration for invalid values
+func (hc *HystrixConfig) Validate() error {
+	validationErr := &ValidationError{}
+
+	checkNonNegative(validationErr, "hystrixtimeoutinmillis", int64(hc.hystrixTimeout))
+	checkNonNegative(validationErr, "maxconcurrentrequests", int64(hc.maxConcurrentRequests))
+	checkNonNegative(validationErr, "sleepwindowinmillis", int64(hc.sleepWindowInMillis))
+	checkNonNegative(validationErr, "requestvolumethreshold", int64(hc.requestVolumeThreshold))
+	if hc.errorPercentThreshold < 0 || hc.errorPercentThreshold > 100 {
+		validationErr.add("errorpercentthreshold", "must be between 0 and 100")
+	}
+	checkNonNegative(validationErr, "rollingwindowinmillis", int64(hc.rollingWindowInMillis))
+	checkNonNegative(validationErr, "consecutivefailures", int64(hc.consecutiveFailures))
+	checkNonNegative(validationErr, "halfopenprobes", int64(hc.halfOpenProbes))
+	if hc.mode != "" && hc.mode != CircuitBreakerModeErrorRate && hc.mode != CircuitBreakerModeConsecutive {
+		validationErr.add("mode", "unknown circuit breaker mode %s", hc.mode)
+	}
+
+	return validationErr.errorOrNil()
+}
+
+func checkNonNegative(validationErr *ValidationError, field string, value int64) {
+	if value < 0 {
+		validationErr.add(field, "must not be negative")
+	}
+}
+
+func checkJitterBackoff(validationErr *ValidationError, field string, jitterBackoff *JitterBackoff) {
+	if jitterBackoff == nil {
+		return
+	}
+	checkNonNegative(validationErr, field+".initialtimeoutinmillis", int64(jitterBackoff.initialTimeout))
+	checkNonNegative(validationErr, field+".maxtimeoutinmillis", int64(jitterBackoff.maxTimeout))
+	if jitterBackoff.maxTimeout > 0 && jitterBackoff.maxTimeout < jitterBackoff.initialTimeout {
+		validationErr.add(field+".maxtimeoutinmillis", "must not be less than initialtimeoutinmillis")
+	}
+}
+
+func isBackoffStrategy(strategy BackoffStrategy) bool {
+	for _, s := range backoffStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// configKind is the expected type of value for a key in the config map
+type configKind int
+
+const (
+	kindInt configKind = iota
+	kindFloat
+	kindString
+	kindBool
+	kindMap
+	kindAnyMap
+	kindIntSlice
+	kindStringSlice
+	kindEndpoints
+)
+
+// configField describes a key of the config map, nested is set only for the maps with known keys
+type configField struct {
+	kind   configKind
+	nested map[string]configField
+}
+
+var constantBackoffSchema = map[string]configField{
+	"intervalinmillis":          {kind: kindInt},
+	"maxjitterintervalinmillis": {kind: kindInt},
+}
+
+var exponentialBackoffSchema = map[string]configField{
+	"initialtimeoutinmillis":    {kind: kindInt},
+	"maxtimeoutinmillis":        {kind: kindInt},
+	"exponentfactor":            {kind: kindFloat},
+	"maxjitterintervalinmillis": {kind: kindInt},
+}
+
+var jitterBackoffSchema = map[string]configField{
+	"initialtimeoutinmillis": {kind: kindInt},
+	"maxtimeoutinmillis":     {kind: kindInt},
+}
+
+var fibonacciBackoffSchema = map[string]configField{
+	"intervalinmillis":          {kind: kindInt},
+	"maxtimeoutinmillis":        {kind: kindInt},
+	"maxjitterintervalinmillis": {kind: kindInt},
+}
+
+var linearBackoffSchema = map[string]configField{
+	"initialtimeoutinmillis":    {kind: kindInt},
+	"incrementinmillis":         {kind: kindInt},
+	"maxtimeoutinmillis":        {kind: kindInt},
+	"maxjitterintervalinmillis": {kind: kindInt},
+}
+
+var backoffPolicySchema = map[string]configField{
+	"strategy":                  {kind: kindString},
+	"constantbackoff":           {kind: kindMap, nested: constantBackoffSchema},
+	"exponentialbackoff":        {kind: kindMap, nested: exponentialBackoffSchema},
+	"fulljitterbackoff":         {kind: kindMap, nested: jitterBackoffSchema},
+	"equaljitterbackoff":        {kind: kindMap, nested: jitterBackoffSchema},
+	"decorrelatedjitterbackoff": {kind: kindMap, nested: jitterBackoffSchema},
+	"fibonaccibackoff":          {kind: kindMap, nested: fibonacciBackoffSchema},
+	"linearbackoff":             {kind: kindMap, nested: linearBackoffSchema},
+	"maxretryafterinmillis":     {kind: kindInt},
+}
+
+var hedgingSchema = map[string]configField{
+	"delayinmillis": {kind: kindInt},
+	"maxhedges":     {kind: kindInt},
+	"percentile":    {kind: kindFloat},
+}
+
+var retryBudgetSchema = map[string]configField{
+	"ratio":               {kind: kindFloat},
+	"minretriespersecond": {kind: kindFloat},
+	"windowinmillis":      {kind: kindInt},
+}
+
+var rateLimitSchema = map[string]configField{
+	"rps":    {kind: kindFloat},
+	"burst":  {kind: kindInt},
+	"mode":   {kind: kindString},
+	"shared": {kind: kindString},
+}
+
+var concurrencyLimitSchema = map[string]configField{
+	"initiallimit":             {kind: kindInt},
+	"minlimit":                 {kind: kindInt},
+	"maxlimit":                 {kind: kindInt},
+	"latencythresholdinmillis": {kind: kindInt},
+	"backoffratio":             {kind: kindFloat},
+}
+
+var bulkheadSchema = map[string]configField{
+	"maxconcurrentrequests": {kind: kindInt},
+	"maxqueuesize":          {kind: kindInt},
+	"queuetimeoutinmillis":  {kind: kindInt},
+}
+
+var loadBalancerSchema = map[string]configField{
+	"strategy":             {kind: kindString},
+	"consecutivefailures":  {kind: kindInt},
+	"ejectiontimeinmillis": {kind: kindInt},
+	"maxejectionpercent":   {kind: kindInt},
+}
+
+var retryPolicySchema = map[string]configField{
+	"statuscodes":        {kind: kindIntSlice},
+	"errors":             {kind: kindStringSlice},
+	"retrynonidempotent": {kind: kindBool},
+	"idempotencykey":     {kind: kindBool},
+}
+
+var hystrixConfigSchema = map[string]configField{
+	"hystrixtimeoutinmillis": {kind: kindInt},
+	"maxconcurrentrequests":  {kind: kindInt},
+	"errorpercentthreshold":  {kind: kindInt},
+	"sleepwindowinmillis":    {kind: kindInt},
+	"requestvolumethreshold": {kind: kindInt},
+	"rollingwindowinmillis":  {kind: kindInt},
+	"mode":                   {kind: kindString},
+	"consecutivefailures":    {kind: kindInt},
+	"halfopenprobes":         {kind: kindInt},
+}
+
+var requestConfigSchema = map[string]configField{
+	"method":                        {kind: kindString},
+	"url":                           {kind: kindString},
+	"path":                          {kind: kindString},
+	"timeoutinmillis":               {kind: kindInt},
+	"totaltimeoutinmillis":          {kind: kindInt},
+	"connecttimeoutinmillis":        {kind: kindInt},
+	"keepaliveinmillis":             {kind: kindInt},
+	"maxidleconnections":            {kind: kindInt},
+	"idleconnectiontimeoutinmillis": {kind: kindInt},
+	"tlshandshaketimeoutinmillis":   {kind: kindInt},
+	"expectcontinuetimeoutinmillis": {kind: kindInt},
+	"proxyurl":                      {kind: kindString},
+	"retrycount":                    {kind: kindInt},
+	"maxbodybuffersizeinbytes":      {kind: kindInt},
+	"backoffpolicy":                 {kind: kindMap, nested: backoffPolicySchema},
+	"retrybudget":                   {kind: kindMap, nested: retryBudgetSchema},
+	"hedging":                       {kind: kindMap, nested: hedgingSchema},
+	"ratelimit":                     {kind: kindMap, nested: rateLimitSchema},
+	"concurrencylimit":              {kind: kindMap, nested: concurrencyLimitSchema},
+	"bulkhead":                      {kind: kindMap, nested: bulkheadSchema},
+	"urls":                          {kind: kindEndpoints},
+	"loadbalancer":                  {kind: kindMap, nested: loadBalancerSchema},
+	"retrypolicy":                   {kind: kindMap, nested: retryPolicySchema},
+	"hystrixconfig":                 {kind: kindMap, nested: hystrixConfigSchema},
+	"headers":                       {kind: kindAnyMap},
+	"tlsminversion":                 {kind: kindString},
+}
+
+// This checks the keys of the config map against the schema, reporting the unknown keys and
+// the values which cannot be converted to the expected type.
+func checkConfigMap(validationErr *ValidationError, prefix string, configMap map[string]interface{},
+	schema map[string]configField) {
+	keys := make([]string, 0, len(configMap))
+	for key := range configMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := joinField(prefix, strings.ToLower(key))
+		expected, ok := schema[strings.ToLower(key)]
+		if !ok {
+			validationErr.add(field, "unknown key")
+			continue
+		}
+
+		var err error
+		value := configMap[key]
+		switch expected.kind {
+		case kindInt:
+			_, err = cast.ToIntE(value)
+		case kindFloat:
+			_, err = cast.ToFloat64E(value)
+		case kindString:
+			_, err = cast.ToStringE(value)
+		case kindBool:
+			_, err = cast.ToBoolE(value)
+		case kindIntSlice:
+			_, err = cast.ToIntSliceE(value)
+		case kindStringSlice:
+			_, err = cast.ToStringSliceE(value)
+		case kindEndpoints:
+			_, err = toEndpointsE(value)
+		case kindMap, kindAnyMap:
+			var nested map[string]interface{}
+			nested, err = cast.ToStringMapE(value)
+			if err == nil && expected.nested != nil {
+				checkConfigMap(validationErr, field, nested, expected.nested)
+			}
+		}
+		if err != nil {
+			validationErr.add(field, "invalid value %v", value)
+		}
+	}
+}
+
+func joinField(prefix, field string) string {
+	if prefix == "" {
+		return field
+	}
+	if field == "" {
+		return prefix
+	}
+	return prefix + "." + field
+}
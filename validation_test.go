@@ -0,0 +1,57 @@
+package httpclient
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequestConfigStrict(t *testing.T) {
+	_, err := NewRequestConfigStrict("test", map[string]interface{}{
+		"method":          "GET",
+		"timeoutInMillis": -5,
+		"retrycount":      "three",
+		"backoffpolicy": map[string]interface{}{
+			"constantbackoff": map[string]interface{}{
+				"intervalinmillis": 2,
+			},
+			"exponentialbackoff": map[string]interface{}{
+				"initialtimeoutinmillis": 2,
+				"maxtimeoutinmillis":     10,
+			},
+		},
+		"hystrixConfig": map[string]interface{}{
+			"errorPercentThresold":   20,
+			"hystrixTimeoutInMillis": -1,
+		},
+	})
+	require.Error(t, err)
+
+	validationErr, ok := err.(*ValidationError)
+	require.True(t, ok)
+
+	// the field paths are lowercase whatever the casing of the keys
+	problems := make(map[string]string)
+	for _, e := range validationErr.Errors {
+		require.Equal(t, e.Field, strings.ToLower(e.Field))
+		problems[e.Field] = e.Message
+	}
+	assert.Equal(t, problems["retrycount"], "invalid value three")
+	assert.Equal(t, problems["hystrixconfig.errorpercentthresold"], "unknown key")
+	assert.Equal(t, problems["url"], "is mandatory")
+	assert.Equal(t, problems["timeoutinmillis"], "must not be negative")
+	assert.Equal(t, problems["hystrixconfig.hystrixtimeoutinmillis"], "must not be negative")
+	assert.Equal(t, problems["backoffpolicy"], "only one of constantbackoff and exponentialbackoff can be set without strategy")
+
+	rc, err := NewRequestConfigStrict("test", map[string]interface{}{
+		"method": "GET",
+		"url":    "http://localhost",
+		"hystrixConfig": map[string]interface{}{
+			"errorPercentThreshold": 20,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, rc.Validate())
+}